@@ -6,5 +6,6 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.7.0
 	github.com/tealeg/xlsx v1.0.5
+	golang.org/x/text v0.3.7
 	gonum.org/v1/plot v0.8.1
 )
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"math/rand"
 	"os"
+	"regexp"
 	"runtime/debug"
 	"sort"
 	"strings"
@@ -21,6 +23,17 @@ const (
 
 var (
 	soundsPath       = flag.String("sounds", "./data/sounds.xlsx", "path to file containing sound classes")
+	soundModel       = flag.String("sound_model", "", "comma-separated built-in sound models to use instead of --sounds (dolgopolsky, sca, asjp)")
+	profilesPath     = flag.String("profiles", "", "path to directory with orthography profiles (<language>.tsv)")
+	affixesPath      = flag.String("affixes", "", "path to TSV file with affixes stripped from forms before decoding (LANGUAGE, AFFIX, PATTERN)")
+	strict           = flag.Bool("strict", false, "fail if a form contains characters missing from the sound model")
+	normalization    = flag.String("normalize", "none", "Unicode normalization of forms and sound models before decoding: none, nfc or nfd")
+	foldCase         = flag.Bool("fold_case", false, "lower the case of forms before decoding")
+	conceptMapPath   = flag.String("concept_map", "", "path to TSV file mapping concept IDs or glosses of wordlists to canonical concepts (ID/GLOSS, CONCEPTICON_ID, CONCEPTICON_GLOSS)")
+	conceptMapA      = flag.String("concept_map_a", "", "concept map for --set_a (overrides --concept_map)")
+	conceptMapB      = flag.String("concept_map_b", "", "concept map for --set_b (overrides --concept_map)")
+	conceptsSpec     = flag.String("concepts", "", "compare a subset of concepts: swadesh100, yakhontov35, leipzig-jakarta or path to file with an ID or gloss per line")
+	exclusionsPath   = flag.String("exclude", "", "path to TSV file listing concepts and forms to leave out (LANGUAGE, CONCEPT, FORM, REASON)")
 	wordlistsPath    = flag.String("wordlists", "./data/wordlists.xlsx", "path to file containing wordlists")
 	setA             = flag.String("set_a", "", "path to file containing wordlists for A (triggers AB mode)")
 	setB             = flag.String("set_b", "", "path to file containing wordlists for B (triggers AB mode)")
@@ -31,14 +44,90 @@ var (
 	consonantPath    = flag.String("consonants", "", "path to file with consonant encodings")
 	lang1            = flag.String("lang_1", "", "first language to compare (optional)")
 	lang2            = flag.String("lang_2", "", "second language to compare (optional)")
+	langRegex        = flag.String("lang_regex", "", "regular expression selecting languages by column name")
+	excludeLangs     = flag.String("exclude_lang", "", "comma-separated languages to leave out")
 	allPairs         = flag.Bool("all_pairs", false, "compare each wordlist in file")
 	verbose          = flag.Bool("verbose", false, "verbose output")
 	numTrials        = flag.Int("num_trials", 1000000, "number of trials")
+	matchMode        = flag.String("match", "prefix", "match rule: prefix (first --match_length classes) or subsequence (ordered subsequence of --match_length classes)")
+	matchLength      = flag.Int("match_length", 2, "number of classes that must match")
+	metathesis       = flag.Float64("metathesis", 0, "score of roots that match after swapping their first two classes (0 disables metathesis)")
+	doubtful         = flag.Float64("doubtful", 0.5, "factor applied to scores of forms marked as uncertain with \"?\" (1 scores them like other forms, below 1 leaves their matches out of N)")
+	shortForms       = flag.String("short_forms", "exact", "policy for roots shorter than --match_length: exact, reject or truncate")
+	synonyms         = flag.String("synonyms", "any", "forms compared for concepts with several forms: any, first, penalised (score divided by the number of form pairs) or cap (first --max_forms forms)")
+	maxForms         = flag.Int("max_forms", 0, "number of forms per concept compared with --synonyms=cap")
+	missing          = flag.String("missing", "keep", "concepts without forms in either language: keep (they take part in the shuffles) or drop")
+	evalCognates     = flag.Bool("evaluate_cognates", false, "evaluate matches against cognate indices of the wordlists (precision, recall, F1)")
+	lexicostatistics = flag.Bool("lexicostatistics", false, "print cognate percentages, divergence times and a dated tree of the selected languages (requires cognate indices)")
+	retention        = flag.Float64("retention", src.DefaultRetention, "Swadesh retention rate per millennium")
+	starostinRate    = flag.Float64("starostin_rate", src.DefaultStarostinRate, "Starostin rate of change per millennium squared")
+	treeFormula      = flag.String("tree", "starostin", "divergence times used for the tree: starostin or swadesh")
+	nexusPath        = flag.String("nexus", "", "path to NEXUS file to write the binary cognate matrix of the selected languages to")
+	formCounts       = flag.Bool("form_counts", false, "report concepts with several forms to compare")
+	statistic        = flag.String("statistic", "match", "comma-separated test statistics: match (number of matches), graded (sum of class similarity scores), ldn or ldnd (normalized edit distance)")
+	ldnForms         = flag.String("ldn_forms", "segmented", "forms compared by ldn and ldnd: segmented (sounds of clean forms) or decoded (roots)")
+	minCoverage      = flag.Float64("min_coverage", 0.5, "share of attested concepts below which validate warns about a language")
 	abMode           bool
+	langs            langList
 )
 
+// runConfig holds what every comparison of a run needs besides the flags.
+type runConfig struct {
+	decoder *src.SoundClassesDecoder
+	weights src.Weights
+	tests   []*statisticTest
+	// model is the sound model of the run when several models are compared, empty otherwise.
+	model string
+	// concepts is the subset of concepts to compare, nil to compare all concepts.
+	concepts *src.ConceptSubset
+	// exclusions lists concepts and forms left out of comparisons, nil to compare everything.
+	exclusions *src.ExclusionList
+	// results collects p-values and distances of every pair for the all pairs matrix.
+	results *resultMatrix
+	// exportNexus is set for the first sound model only, the cognate matrix does not depend on it.
+	exportNexus bool
+}
+
+// langList is a flag that may be repeated and holds comma-separated languages.
+type langList []string
+
+func (l *langList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *langList) Set(value string) error {
+	for _, lang := range strings.Split(value, ",") {
+		if lang = strings.TrimSpace(lang); len(lang) > 0 {
+			*l = append(*l, lang)
+		}
+	}
+
+	return nil
+}
+
 func init() {
+	flag.Var(&langs, "lang", "language to compare, may be repeated or hold comma-separated languages")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s [validate]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	rand.Seed(time.Now().UnixNano())
+	log.SetFlags(0)
+}
+
+// parseArgs parses the flags and tells whether the validate command was given,
+// which checks wordlists without running tests.
+func parseArgs() (validate bool) {
 	flag.Parse()
+	if flag.Arg(0) == "validate" {
+		validate = true
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if flag.NArg() > 0 {
+		log.Printf("Unexpected argument(s): %s, exiting", strings.Join(flag.Args(), " "))
+		os.Exit(1)
+	}
 
 	if len(*setA) > 0 || len(*setB) > 0 {
 		if len(*setA) == 0 || len(*setB) == 0 {
@@ -49,11 +138,12 @@ func init() {
 		abMode = true
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	log.SetFlags(0)
+	return validate
 }
 
 func main() {
+	var validate = parseArgs()
+
 	defer func() {
 		if e := recover(); e != nil {
 			currDir, _ := os.Getwd()
@@ -71,41 +161,259 @@ func main() {
 		}
 	}
 
-	if abMode {
-		runPermutationTestAB(weights)
-	} else {
-		runPermutationTest(weights)
+	rule, err := src.NewMatchRule(*matchMode, *matchLength, *shortForms)
+	if err != nil {
+		log.Println("Invalid match rule:", err)
+		return
+	}
+	if *metathesis < 0 || *metathesis > 1 {
+		log.Printf("Metathesis score must be between 0 and 1, got %f", *metathesis)
+		return
+	}
+	rule.Metathesis = *metathesis
+	if *doubtful <= 0 || *doubtful > 1 {
+		log.Printf("Doubtful forms factor must be above 0 and at most 1, got %f", *doubtful)
+		return
+	}
+	rule.Doubtful = *doubtful
+	if err := rule.SetSynonymPolicy(*synonyms, *maxForms); err != nil {
+		log.Println("Invalid match rule:", err)
+		return
+	}
+	if validate {
+		os.Exit(runValidate())
+	}
+
+	var statistics []string
+	for _, name := range strings.Split(*statistic, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case statisticMatch, statisticGraded, statisticLDN, statisticLDND:
+			statistics = append(statistics, name)
+		default:
+			log.Printf("Unknown statistic %q (expected match, graded, ldn or ldnd)", name)
+			return
+		}
+	}
+	if *missing != "keep" && *missing != "drop" {
+		log.Printf("Unknown missing data policy %q (expected keep or drop)", *missing)
+		return
+	}
+	if *ldnForms != "segmented" && *ldnForms != "decoded" {
+		log.Printf("Unknown ldn forms %q (expected segmented or decoded)", *ldnForms)
+		return
+	}
+
+	if *treeFormula != "starostin" && *treeFormula != "swadesh" {
+		log.Printf("Unknown tree formula %q (expected starostin or swadesh)", *treeFormula)
+		return
+	}
+
+	var (
+		conceptSubset *src.ConceptSubset
+		exclusions    *src.ExclusionList
+		results       = newResultMatrix()
+	)
+	if len(*conceptsSpec) > 0 {
+		// Built-in lists use Swadesh-110 IDs unless concepts are renumbered by a concept map.
+		var swadeshIDs = len(*conceptMapPath) == 0 && len(*conceptMapA) == 0 && len(*conceptMapB) == 0
+		if conceptSubset, err = src.NewConceptSubset(*conceptsSpec, swadeshIDs); err != nil {
+			log.Println("Failed to load concepts:", err)
+			return
+		}
+	}
+
+	if len(*exclusionsPath) > 0 {
+		if exclusions, err = src.NewExclusionList(*exclusionsPath); err != nil {
+			log.Println("Failed to load exclusions:", err)
+			return
+		}
+	}
+
+	var models = []string{""}
+	if len(*soundModel) > 0 {
+		models = strings.Split(*soundModel, ",")
+	}
+
+	for idx, model := range models {
+		model = strings.TrimSpace(model)
+		decoder, err := newDecoder(model)
+		if err != nil {
+			log.Println("Failed to load sound classes info:", err)
+			return
+		}
+
+		var cfg = &runConfig{
+			decoder:    decoder,
+			weights:    weights,
+			concepts:   conceptSubset,
+			exclusions: exclusions,
+			results:    results,
+
+			exportNexus: idx == 0 && len(*nexusPath) > 0,
+		}
+		if len(models) > 1 {
+			cfg.model = model
+		}
+
+		var tests []*statisticTest
+		for _, name := range statistics {
+			var test = &statisticTest{name: name}
+			switch name {
+			case statisticMatch:
+				var modelRule = *rule
+				test.rule = &modelRule
+			case statisticGraded:
+				if decoder.Similarity == nil {
+					log.Println("Sound model has no similarity matrix, graded scores are not available")
+					return
+				}
+				var modelRule = *rule
+				modelRule.Similarity = decoder.Similarity
+				test.rule = &modelRule
+			}
+			tests = append(tests, test)
+		}
+		cfg.tests = tests
+
+		if abMode {
+			runPermutationTestAB(cfg)
+		} else {
+			runPermutationTest(cfg)
+		}
+	}
+
+	if *allPairs && !abMode {
+		// Per-pair output may have been redirected to a file that is closed by now.
+		log.SetOutput(os.Stderr)
+		results.Print()
 	}
 }
 
-func runPermutationTest(weights src.Weights) {
-	decoder, err := src.NewSoundClassesDecoder(*soundsPath)
+// runValidate checks wordlists files and returns the exit code.
+func runValidate() int {
+	var model string
+	if len(*soundModel) > 0 {
+		model = strings.TrimSpace(strings.Split(*soundModel, ",")[0])
+	}
+	decoder, err := newDecoder(model)
 	if err != nil {
 		log.Println("Failed to load sound classes info:", err)
-		return
+		return 1
 	}
 
-	var selectedLangs map[string]bool
-	if len(*lang1) > 0 && len(*lang2) > 0 {
-		selectedLangs = map[string]bool{*lang1: true, *lang2: true}
+	var (
+		paths    = []string{*wordlistsPath}
+		selector *src.LanguageSelector
+	)
+	if abMode {
+		paths = []string{*setA, *setB}
+	} else if selector, err = newLanguageSelector(); err != nil {
+		log.Println("Invalid language selection:", err)
+		return 1
+	}
+
+	var exitCode int
+	for _, path := range paths {
+		report, err := decoder.Validate(path, selector, *minCoverage)
+		if err != nil {
+			log.Printf("Failed to validate %s: %s", path, err)
+			exitCode = 1
+			continue
+		}
+
+		report.Print()
+		if report.NumErrors() > 0 {
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
+
+func newDecoder(model string) (*src.SoundClassesDecoder, error) {
+	var (
+		decoder *src.SoundClassesDecoder
+		err     error
+	)
+	if len(model) > 0 {
+		decoder, err = src.NewSoundClassesDecoderFromPreset(model)
+	} else {
+		decoder, err = src.NewSoundClassesDecoder(*soundsPath)
 	}
-	wordlists, err := decoder.Decode(*wordlistsPath, selectedLangs)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder.Strict = *strict
+	if err := decoder.SetNormalization(*normalization, *foldCase); err != nil {
+		return nil, err
+	}
+	if len(*conceptMapPath) > 0 {
+		if decoder.Concepts, err = src.NewConceptMap(*conceptMapPath); err != nil {
+			return nil, err
+		}
+	}
+	if len(*profilesPath) > 0 {
+		if decoder.Profiles, err = src.LoadOrthographyProfiles(*profilesPath); err != nil {
+			return nil, err
+		}
+	}
+	if len(*affixesPath) > 0 {
+		if decoder.Affixes, err = src.LoadAffixes(*affixesPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return decoder, nil
+}
+
+func runPermutationTest(cfg *runConfig) {
+	var decoder = cfg.decoder
+	selector, err := newLanguageSelector()
+	if err != nil {
+		log.Println("Invalid language selection:", err)
+		return
+	}
+	wordlists, err := decoder.Decode(*wordlistsPath, selector)
+	decoder.PrintUnknownSounds()
 	if err != nil {
 		log.Println("Failed to decode wordlists:", err)
 		return
 	}
+	if len(wordlists) < 2 {
+		log.Printf("At least two languages are needed, %d selected", len(wordlists))
+		return
+	}
+	if cfg.exclusions != nil {
+		cfg.exclusions.PrintUnmatched(wordlists)
+	}
+	if *lexicostatistics {
+		runLexicostatistics(cfg, wordlists)
+	}
+	if cfg.exportNexus {
+		var filtered = make([]*src.Wordlist, len(wordlists))
+		for idx, list := range wordlists {
+			filtered[idx] = cfg.filterList(list)
+		}
+		if err := src.WriteNexus(*nexusPath, filtered); err != nil {
+			log.Println("Failed to export cognate matrix:", err)
+		} else {
+			log.Printf("Cognate matrix saved at %s", *nexusPath)
+		}
+	}
+	if len(wordlists) > 2 && !*allPairs {
+		log.Printf("%d languages are selected, comparing %s with %s (use -all_pairs to compare all of them)",
+			len(wordlists), wordlists[0].Group, wordlists[1].Group)
+	}
 
 	if *allPairs {
 		for i := 0; i < len(wordlists); i++ {
-			printConsonants(wordlists[i])
+			printConsonants(cfg, wordlists[i])
 			for j := i; j < len(wordlists); j++ {
 				if i != j {
-					wFile := setupOutput(wordlists[i], wordlists[j])
-					if len(*weightsPath) > 0 {
-						runTestWeighted(wordlists[i], wordlists[j], weights)
-					} else {
-						runTest(wordlists[i], wordlists[j], weights)
-					}
+					wFile := setupOutput(cfg, wordlists[i], wordlists[j])
+					runTests(cfg, wordlists[i], wordlists[j])
 					if wFile != nil {
 						wFile.Close()
 					}
@@ -113,23 +421,96 @@ func runPermutationTest(weights src.Weights) {
 			}
 		}
 	} else {
-		wFile := setupOutput(wordlists[0], wordlists[1])
-		if len(*weightsPath) > 0 {
-			runTestWeighted(wordlists[0], wordlists[1], weights)
-		} else {
-			runTest(wordlists[0], wordlists[1], weights)
-		}
+		wFile := setupOutput(cfg, wordlists[0], wordlists[1])
+		runTests(cfg, wordlists[0], wordlists[1])
 		if wFile != nil {
 			wFile.Close()
 		}
-		printConsonants(wordlists[0])
-		printConsonants(wordlists[1])
+		printConsonants(cfg, wordlists[0])
+		printConsonants(cfg, wordlists[1])
+	}
+}
+
+func newLanguageSelector() (*src.LanguageSelector, error) {
+	var names = append([]string{}, langs...)
+	if len(*lang1) > 0 && len(*lang2) > 0 {
+		names = append(names, *lang1, *lang2)
+	}
+
+	var selector = src.SelectLanguages(names...)
+	if len(*langRegex) > 0 {
+		pattern, err := regexp.Compile(*langRegex)
+		if err != nil {
+			return nil, err
+		}
+		selector.Pattern = pattern
+	}
+
+	var excluded langList
+	_ = excluded.Set(*excludeLangs)
+	selector.Exclude = map[string]bool{}
+	for _, lang := range excluded {
+		selector.Exclude[lang] = true
+	}
+
+	return selector, nil
+}
+
+func runLexicostatistics(cfg *runConfig, wordlists []*src.Wordlist) {
+	var (
+		matrix    = newResultMatrix()
+		names     []string
+		distances = make([][]float64, len(wordlists))
+	)
+	for i := range wordlists {
+		names = append(names, wordlists[i].Group)
+		// Distances of pairs without cognate indices in common stay missing.
+		distances[i] = make([]float64, len(wordlists))
+		for j := range distances[i] {
+			if i != j {
+				distances[i][j] = math.NaN()
+			}
+		}
+	}
+
+	log.Printf("\n[Lexicostatistics]")
+	for i := 0; i < len(wordlists); i++ {
+		for j := i + 1; j < len(wordlists); j++ {
+			var l1, l2, _ = cfg.filter(wordlists[i], wordlists[j])
+			percentage, shared, total := src.CognatePercentage(l1, l2)
+			if total == 0 {
+				log.Printf("%s and %s have no concepts with cognate indices in common", names[i], names[j])
+				continue
+			}
+			var (
+				swadeshTime   = src.SwadeshTime(percentage, *retention)
+				starostinTime = src.StarostinTime(percentage, *starostinRate)
+			)
+			log.Printf("%s - %s: %d / %d cognates", names[i], names[j], shared, total)
+
+			matrix.Add("Cognates, %", names[i], names[j], 100*percentage)
+			matrix.Add(fmt.Sprintf("Swadesh divergence, millennia (r = %.2f)", *retention), names[i], names[j], swadeshTime)
+			matrix.Add(fmt.Sprintf("Starostin divergence, millennia (λ = %.3f)", *starostinRate), names[i], names[j], starostinTime)
+
+			distances[i][j], distances[j][i] = starostinTime, starostinTime
+			if *treeFormula == "swadesh" {
+				distances[i][j], distances[j][i] = swadeshTime, swadeshTime
+			}
+		}
+	}
+	matrix.Print()
+
+	tree, err := src.UPGMA(names, distances)
+	if err != nil {
+		log.Printf("\nNo tree is built: %s", err)
+		return
 	}
+	log.Printf("\n[Tree (%s divergence, millennia)]\n%s\n", *treeFormula, tree)
 }
 
-func setupOutput(l1, l2 *src.Wordlist) *os.File {
+func setupOutput(cfg *runConfig, l1, l2 *src.Wordlist) *os.File {
 	if len(*outputPath) > 0 {
-		var expOutputPath = expandPath(*outputPath, l1, l2)
+		var expOutputPath = expandPath(cfg, *outputPath, l1, l2)
 		os.Remove(expOutputPath)
 		w, err := os.OpenFile(expOutputPath, os.O_RDWR|os.O_CREATE, 0666)
 		if err != nil {
@@ -144,9 +525,9 @@ func setupOutput(l1, l2 *src.Wordlist) *os.File {
 	return nil
 }
 
-func printConsonants(l1 *src.Wordlist) {
+func printConsonants(cfg *runConfig, l1 *src.Wordlist) {
 	if len(*consonantPath) > 0 {
-		var expConsonantPath = expandPath(*consonantPath, l1, &src.Wordlist{})
+		var expConsonantPath = expandPath(cfg, *consonantPath, l1, &src.Wordlist{})
 		os.Remove(expConsonantPath)
 		consonantW, err := os.OpenFile(expConsonantPath, os.O_RDWR|os.O_CREATE, 0666)
 		if err != nil {
@@ -156,19 +537,25 @@ func printConsonants(l1 *src.Wordlist) {
 			log.SetOutput(consonantW)
 		}
 
+		if cfg.exclusions != nil {
+			l1, _ = cfg.exclusions.Apply(l1)
+		}
 		l1.PrintTransformations()
 	}
 }
 
-func runPermutationTestAB(weights src.Weights) {
-	decoder, err := src.NewSoundClassesDecoder(*soundsPath)
-	if err != nil {
-		log.Println("Failed to load sound classes info:", err)
+func runPermutationTestAB(cfg *runConfig) {
+	var (
+		decoder         = cfg.decoder
+		defaultConcepts = decoder.Concepts
+	)
+	if err := setConceptMap(decoder, *conceptMapA, defaultConcepts); err != nil {
+		log.Println("Failed to read concept map for A:", err)
 		return
 	}
-
 	wordlistsA, err := decoder.Decode(*setA, nil)
 	if err != nil {
+		decoder.PrintUnknownSounds()
 		log.Println("Failed to decode wordlists A:", err)
 		return
 	}
@@ -178,7 +565,12 @@ func runPermutationTestAB(weights src.Weights) {
 		combinedA = combinedA.Combine(wordlistsA[idx])
 	}
 
+	if err := setConceptMap(decoder, *conceptMapB, defaultConcepts); err != nil {
+		log.Println("Failed to read concept map for B:", err)
+		return
+	}
 	wordlistsB, err := decoder.Decode(*setB, nil)
+	decoder.PrintUnknownSounds()
 	if err != nil {
 		log.Println("Failed to decode wordlists B:", err)
 		return
@@ -188,36 +580,128 @@ func runPermutationTestAB(weights src.Weights) {
 	for idx := 1; idx < len(wordlistsB); idx++ {
 		combinedB = combinedB.Combine(wordlistsB[idx])
 	}
-
-	wFile := setupOutput(combinedA, combinedB)
-	if len(*weightsPath) > 0 {
-		runTestWeighted(combinedA, combinedB, weights)
-	} else {
-		runTest(combinedA, combinedB, weights)
+	if cfg.exclusions != nil {
+		cfg.exclusions.PrintUnmatched(append(append([]*src.Wordlist{}, wordlistsA...), wordlistsB...))
 	}
+
+	wFile := setupOutput(cfg, combinedA, combinedB)
+	runTests(cfg, combinedA, combinedB)
 	if wFile != nil {
 		wFile.Close()
 	}
-	printConsonants(combinedA)
-	printConsonants(combinedB)
+	printConsonants(cfg, combinedA)
+	printConsonants(cfg, combinedB)
+}
+
+func setConceptMap(decoder *src.SoundClassesDecoder, path string, defaultConcepts *src.ConceptMap) (err error) {
+	decoder.Concepts = defaultConcepts
+	if len(path) > 0 {
+		decoder.Concepts, err = src.NewConceptMap(path)
+	}
+
+	return err
+}
+
+// filter applies the concept subset and the exclusions to a pair of wordlists and
+// returns the excluded items.
+func (c *runConfig) filter(l1, l2 *src.Wordlist) (filtered1, filtered2 *src.Wordlist, excluded []string) {
+	if c.concepts != nil {
+		l1, l2 = c.concepts.Filter(l1), c.concepts.Filter(l2)
+	}
+	if c.exclusions != nil {
+		l1, l2, excluded = c.exclusions.Filter(l1, l2)
+	}
+
+	return l1, l2, excluded
 }
 
-func runTestWeighted(l1, l2 *src.Wordlist, weights src.Weights) {
-	maxCost, group1, group2 := runTest(l1, l2, weights), l1.Group, l2.Group
-	if cost := runTest(l2, l1, weights); cost > maxCost {
+// filterList applies the concept subset and the exclusions to a single wordlist.
+func (c *runConfig) filterList(list *src.Wordlist) *src.Wordlist {
+	if c.concepts != nil {
+		list = c.concepts.Filter(list)
+	}
+	if c.exclusions != nil {
+		list, _ = c.exclusions.Apply(list)
+	}
+
+	return list
+}
+
+func runTests(cfg *runConfig, l1, l2 *src.Wordlist) {
+	l1, l2, excluded := cfg.filter(l1, l2)
+	if cfg.exclusions != nil {
+		log.Printf("\n[Excluded items of %s and %s]", l1.Group, l2.Group)
+		for _, item := range excluded {
+			log.Println(item)
+		}
+		log.Printf("Total: %d item(s)", len(excluded))
+	}
+	log.Printf("\n[Coverage of %s and %s]", l1.Group, l2.Group)
+	l1.PrintCoverage(l2)
+	if *missing == "drop" {
+		l1, l2 = src.DropMissing(l1, l2)
+	}
+
+	if *formCounts {
+		log.Printf("\n[Forms of %s and %s]", l1.Group, l2.Group)
+		l1.PrintFormCounts(l2)
+	}
+	for _, test := range cfg.tests {
+		switch {
+		case test.rule == nil:
+			runDistanceTest(cfg, l1, l2, test.name)
+		case len(*weightsPath) > 0:
+			cfg.addResult("P ("+test.name+")", l1, l2, runTestWeighted(cfg, l1, l2, test.rule))
+		default:
+			pCounts, pCosts := runTest(cfg, l1, l2, test.rule)
+			if test.rule.GradedFor(l1, l2) {
+				pCounts = pCosts
+			}
+			cfg.addResult("P ("+test.name+")", l1, l2, pCounts)
+		}
+	}
+
+	if *evalCognates {
+		for _, test := range cfg.tests {
+			if test.rule != nil {
+				runCognateEvaluation(l1, l2, test.rule)
+			}
+		}
+	}
+}
+
+func runCognateEvaluation(l1, l2 *src.Wordlist, rule *src.MatchRule) {
+	log.Printf("\n[Cognate evaluation of %s with %s]", l1.Group, l2.Group)
+	log.Printf("Match rule: %s", rule)
+
+	evaluation, err := src.EvaluateCognates(l1, l2, rule)
+	if err != nil {
+		log.Println("Failed to evaluate matches:", err)
+		return
+	}
+	evaluation.Print()
+}
+
+func runTestWeighted(cfg *runConfig, l1, l2 *src.Wordlist, rule *src.MatchRule) (maxCost float64) {
+	_, maxCost = runTest(cfg, l1, l2, rule)
+	var group1, group2 = l1.Group, l2.Group
+	if _, cost := runTest(cfg, l2, l1, rule); cost > maxCost {
 		maxCost, group1, group2 = cost, l2.Group, l1.Group
 	}
 
 	log.Printf("\n[FINAL] Max P(costs) = %f (%s, %s)", maxCost, group1, group2)
+
+	return maxCost
 }
 
-func runTest(l1, l2 *src.Wordlist, weights src.Weights) (weightedCost float64) {
-	log.Printf("\n[Comparing %s with %s]", l1.Group, l2.Group)
+func runTest(cfg *runConfig, l1, l2 *src.Wordlist, rule *src.MatchRule) (countsP, weightedCost float64) {
+	printComparisonHeader(cfg, l1, l2)
+	log.Printf("Match rule: %s", rule)
 
-	summary, err := src.CompareWordlists(l1, l2, weights, float64(*numTrials), *verbose)
+	summary, err := src.CompareWordlists(l1, l2, cfg.weights, rule, float64(*numTrials), *verbose)
 	if err != nil {
 		log.Println("Failed to run permutation test:", err)
-		return
+		return 0, 0
 	}
 
 	var sortedCountGroups []int
@@ -228,10 +712,10 @@ func runTest(l1, l2 *src.Wordlist, weights src.Weights) (weightedCost float64) {
 	for _, countGroup := range sortedCountGroups {
 		log.Printf("k = %d:\t%d trial(s)\n", countGroup, summary.Counts[countGroup])
 	}
-	log.Printf("P (counts) = %d / %d = %f\n\n", summary.TotalCounts, *numTrials,
-		float64(summary.TotalCounts)/float64(*numTrials))
+	countsP = float64(summary.TotalCounts) / float64(*numTrials)
+	log.Printf("P (counts) = %d / %d = %f\n\n", summary.TotalCounts, *numTrials, countsP)
 
-	if len(*weightsPath) > 0 {
+	if len(*weightsPath) > 0 || rule.GradedFor(l1, l2) {
 		var sortedCosts []float64
 		for numMatches := range summary.Costs {
 			sortedCosts = append(sortedCosts, numMatches)
@@ -245,7 +729,7 @@ func runTest(l1, l2 *src.Wordlist, weights src.Weights) (weightedCost float64) {
 		log.Printf("P (costs) = %d / %d = %f\n", summary.TotalCost, *numTrials, weightedCost)
 
 		if len(*weightedPlotPath) > 0 {
-			var expWeightedPlotPath = expandPlotPath(*weightedPlotPath, l1, l2)
+			var expWeightedPlotPath = expandPlotPath(cfg, *weightedPlotPath, l1, l2)
 			os.Remove(expWeightedPlotPath)
 			if err := src.PlotCostGroups(expWeightedPlotPath, summary.Costs, *numTrials); err != nil {
 				log.Printf("Failed to plot cost groups: %s", err)
@@ -256,7 +740,7 @@ func runTest(l1, l2 *src.Wordlist, weights src.Weights) (weightedCost float64) {
 	}
 
 	if len(*plotPath) > 0 {
-		var expPlotPath = expandPlotPath(*plotPath, l1, l2)
+		var expPlotPath = expandPlotPath(cfg, *plotPath, l1, l2)
 		os.Remove(expPlotPath)
 		if err := src.PlotCountGroups(expPlotPath, summary.Counts, *numTrials); err != nil {
 			log.Printf("Failed to plot count groups: %s", err)
@@ -265,14 +749,63 @@ func runTest(l1, l2 *src.Wordlist, weights src.Weights) (weightedCost float64) {
 		}
 	}
 
-	return weightedCost
+	return countsP, weightedCost
+}
+
+func runDistanceTest(cfg *runConfig, l1, l2 *src.Wordlist, name string) {
+	printComparisonHeader(cfg, l1, l2)
+	log.Printf("Distance: %s over %s forms", strings.ToUpper(name), *ldnForms)
+
+	summary, err := src.CompareDistances(l1, l2, *ldnForms == "segmented", float64(*numTrials))
+	if err != nil {
+		log.Println("Failed to run permutation test:", err)
+		return
+	}
+
+	var distance, total = summary.LDN, summary.TotalLDN
+	if name == statisticLDND {
+		distance, total = summary.LDND, summary.TotalLDND
+	}
+	var p = float64(total) / float64(*numTrials)
+	log.Printf("%s = %f (%d concepts)", strings.ToUpper(name), distance, summary.Concepts)
+	log.Printf("P (%s) = %d / %d = %f\n", name, total, *numTrials, p)
+
+	cfg.addResult("P ("+name+")", l1, l2, p)
+	cfg.addResult(strings.ToUpper(name), l1, l2, distance)
 }
 
-func expandPath(path string, l1, l2 *src.Wordlist) string {
-	return strings.Split(path, ".txt")[0] + fmt.Sprintf("_%s_%s", l1.Group, l2.Group) + ".txt"
+func printComparisonHeader(cfg *runConfig, l1, l2 *src.Wordlist) {
+	log.Printf("\n[Comparing %s with %s]", l1.Group, l2.Group)
+	if len(cfg.model) > 0 {
+		log.Printf("Sound model: %s", cfg.model)
+	}
+	if cfg.concepts != nil {
+		log.Printf("Concepts: %s", cfg.concepts.Name)
+	}
+}
+
+// addResult records a value of a pair for the all pairs matrix, per sound model.
+func (c *runConfig) addResult(name string, l1, l2 *src.Wordlist, value float64) {
+	if len(c.model) > 0 {
+		name += " (" + c.model + ")"
+	}
+	c.results.Add(name, l1.Group, l2.Group, value)
+}
+
+func expandPath(cfg *runConfig, path string, l1, l2 *src.Wordlist) string {
+	return strings.Split(path, ".txt")[0] + expandSuffix(cfg, l1, l2) + ".txt"
+}
+
+func expandSuffix(cfg *runConfig, l1, l2 *src.Wordlist) string {
+	var suffix = fmt.Sprintf("_%s_%s", l1.Group, l2.Group)
+	if len(cfg.model) > 0 {
+		suffix += "_" + cfg.model
+	}
+
+	return suffix
 }
 
-func expandPlotPath(path string, l1, l2 *src.Wordlist) string {
+func expandPlotPath(cfg *runConfig, path string, l1, l2 *src.Wordlist) string {
 	var extension string
 	if strings.Contains(path, ".svg") {
 		extension = ".svg"
@@ -283,5 +816,5 @@ func expandPlotPath(path string, l1, l2 *src.Wordlist) string {
 	} else {
 		log.Panicf("Plot path should contain extension (one of `.svg`, `.png`, `.jpeg`")
 	}
-	return strings.Split(path, extension)[0] + fmt.Sprintf("_%s_%s", l1.Group, l2.Group) + extension
+	return strings.Split(path, extension)[0] + expandSuffix(cfg, l1, l2) + extension
 }
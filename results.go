@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/starling-permutation-test/src"
+)
+
+const (
+	statisticMatch  = "match"
+	statisticGraded = "graded"
+	statisticLDN    = "ldn"
+	statisticLDND   = "ldnd"
+)
+
+// statisticTest is a single test run on every pair of wordlists; rule is nil for
+// edit distance statistics.
+type statisticTest struct {
+	name string
+	rule *src.MatchRule
+}
+
+// resultMatrix keeps a value per statistic and pair of groups in the order they
+// were added.
+type resultMatrix struct {
+	names  []string
+	groups []string
+	values map[string]map[[2]string]float64
+}
+
+func newResultMatrix() *resultMatrix {
+	return &resultMatrix{values: map[string]map[[2]string]float64{}}
+}
+
+func (m *resultMatrix) Add(name, group1, group2 string, value float64) {
+	if _, ok := m.values[name]; !ok {
+		m.names = append(m.names, name)
+		m.values[name] = map[[2]string]float64{}
+	}
+	for _, group := range []string{group1, group2} {
+		if !m.hasGroup(group) {
+			m.groups = append(m.groups, group)
+		}
+	}
+
+	m.values[name][[2]string{group1, group2}] = value
+	m.values[name][[2]string{group2, group1}] = value
+}
+
+func (m *resultMatrix) hasGroup(group string) bool {
+	for _, other := range m.groups {
+		if other == group {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *resultMatrix) Print() {
+	for _, name := range m.names {
+		log.Printf("\n[%s]", name)
+		log.Println("\t" + strings.Join(m.groups, "\t"))
+		for _, group1 := range m.groups {
+			var cells = []string{group1}
+			for _, group2 := range m.groups {
+				if value, ok := m.values[name][[2]string{group1, group2}]; ok {
+					cells = append(cells, fmt.Sprintf("%.4f", value))
+				} else {
+					cells = append(cells, "-")
+				}
+			}
+			log.Println(strings.Join(cells, "\t"))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package src
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	affixLanguageCol = "language"
+	affixCol         = "affix"
+	affixPatternCol  = "pattern"
+	affixMarker      = "-"
+)
+
+// AffixList holds the affixes stripped from forms of a language before decoding.
+type AffixList struct {
+	rules []*affixRule
+}
+
+type affixRule struct {
+	pattern *regexp.Regexp
+	prefix  bool
+}
+
+// LoadAffixes reads affix lists from a TSV file with LANGUAGE, AFFIX and PATTERN
+// columns. An affix is written with a hyphen on the side of the stem: "mu-" is a
+// prefix, "-ni" a suffix, and it is stripped only from forms that have the hyphen
+// too. A pattern is a regular expression that must be anchored
+// either at the start or at the end of the form, e.g. "^(mu|mi|ki)-?"; the part of
+// the form it matches is stripped.
+func LoadAffixes(affixesPath string) (map[string]*AffixList, error) {
+	var out = map[string]*AffixList{}
+	err := readTSV(affixesPath, func(header tsvHeader) error {
+		if !header.has(affixLanguageCol) || !header.has(affixCol) && !header.has(affixPatternCol) {
+			return errors.New("header must contain LANGUAGE and AFFIX or PATTERN columns")
+		}
+		return nil
+	}, func(row *tsvRow) error {
+		var language = row.cell(affixLanguageCol)
+		if len(language) == 0 {
+			return errors.New("language expected")
+		}
+
+		rule, err := newAffixRule(row.cell(affixCol), row.cell(affixPatternCol))
+		if err != nil {
+			return err
+		}
+		if _, ok := out[language]; !ok {
+			out[language] = &AffixList{}
+		}
+		out[language].rules = append(out[language].rules, rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func newAffixRule(affix, pattern string) (*affixRule, error) {
+	if len(pattern) > 0 {
+		var isPrefix, isSuffix = strings.HasPrefix(pattern, "^"), strings.HasSuffix(pattern, "$")
+		if isPrefix == isSuffix {
+			return nil, errors.Errorf("pattern %q must be anchored either at the start (^) or at the end ($)", pattern)
+		}
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &affixRule{pattern: compiled, prefix: isPrefix}, nil
+	}
+
+	var (
+		isPrefix = strings.HasSuffix(affix, affixMarker)
+		isSuffix = strings.HasPrefix(affix, affixMarker)
+		bare     = regexp.QuoteMeta(strings.Trim(affix, affixMarker))
+	)
+	switch {
+	case len(bare) == 0 || isPrefix == isSuffix:
+		return nil, errors.Errorf("affix %q must have a hyphen either after (prefix) or before (suffix) it", affix)
+	case isPrefix:
+		return &affixRule{pattern: regexp.MustCompile("^" + bare + affixMarker), prefix: true}, nil
+	default:
+		return &affixRule{pattern: regexp.MustCompile(affixMarker + bare + "$")}, nil
+	}
+}
+
+// Strip removes affixes from a form, every rule is applied once. An affix is not
+// stripped if nothing would be left of the form.
+func (l *AffixList) Strip(form string) (stem string, affixes []string) {
+	stem = strings.TrimSpace(form)
+	if l == nil {
+		return stem, nil
+	}
+
+	for _, rule := range l.rules {
+		var loc = rule.pattern.FindStringIndex(stem)
+		if loc == nil || loc[0] == loc[1] {
+			continue
+		}
+
+		var rest = strings.TrimSpace(stem[:loc[0]] + stem[loc[1]:])
+		if len(strings.Trim(rest, affixMarker)) == 0 {
+			continue
+		}
+
+		var affix = strings.Trim(stem[loc[0]:loc[1]], affixMarker)
+		if rule.prefix {
+			affix += affixMarker
+		} else {
+			affix = affixMarker + affix
+		}
+		stem, affixes = rest, append(affixes, affix)
+	}
+
+	return stem, affixes
+}
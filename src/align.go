@@ -0,0 +1,158 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Align joins two wordlists on Swadesh IDs: the aligned lists hold the concepts
+// present in both, in the order of the first list. Concepts present in a single
+// list are returned separately. Forms of words sharing a Swadesh ID are merged.
+func Align(list1, list2 *Wordlist) (aligned1, aligned2 *Wordlist, only1, only2 []*Word) {
+	aligned1 = &Wordlist{Group: list1.Group}
+	aligned2 = &Wordlist{Group: list2.Group}
+
+	var (
+		words1, words2 = mergeDuplicates(list1.List), mergeDuplicates(list2.List)
+		idToWord       = map[int]*Word{}
+	)
+	for _, word := range words2 {
+		idToWord[word.SwadeshID] = word
+	}
+
+	var seen = map[int]bool{}
+	for _, word1 := range words1 {
+		seen[word1.SwadeshID] = true
+		if word2, ok := idToWord[word1.SwadeshID]; ok {
+			aligned1.List = append(aligned1.List, word1)
+			aligned2.List = append(aligned2.List, word2)
+		} else {
+			only1 = append(only1, word1)
+		}
+	}
+
+	for _, word2 := range words2 {
+		if !seen[word2.SwadeshID] {
+			only2 = append(only2, word2)
+		}
+	}
+
+	return aligned1, aligned2, only1, only2
+}
+
+// mergeDuplicates keeps the first word of every Swadesh ID with the forms of the
+// later words of that ID appended. Merged words are copies, others are kept as they are.
+func mergeDuplicates(words []*Word) []*Word {
+	var (
+		out      []*Word
+		idToIdx  = map[int]int{}
+		isCopied = map[int]bool{}
+	)
+	for _, word := range words {
+		idx, ok := idToIdx[word.SwadeshID]
+		if !ok {
+			idToIdx[word.SwadeshID] = len(out)
+			out = append(out, word)
+			continue
+		}
+
+		if !isCopied[idx] {
+			out[idx], isCopied[idx] = out[idx].DeepCopy(), true
+		}
+		out[idx].appendForms(word)
+	}
+
+	return out
+}
+
+// Attested tells whether a word has at least one decoded form.
+func (w *Word) Attested() bool {
+	for _, decoded := range w.DecodedForms {
+		if len(decoded) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DropMissing removes concepts without decoded forms in either list. Concepts
+// present in a single list are kept if attested there.
+func DropMissing(list1, list2 *Wordlist) (filtered1, filtered2 *Wordlist) {
+	return dropMissing(list1, list2), dropMissing(list2, list1)
+}
+
+func dropMissing(list, other *Wordlist) *Wordlist {
+	var otherAttested = map[int]bool{}
+	for _, word := range other.List {
+		otherAttested[word.SwadeshID] = otherAttested[word.SwadeshID] || word.Attested()
+	}
+
+	var out = &Wordlist{Group: list.Group}
+	for _, word := range list.List {
+		if attested, ok := otherAttested[word.SwadeshID]; word.Attested() && (attested || !ok) {
+			out.List = append(out.List, word)
+		}
+	}
+
+	return out
+}
+
+// PrintCoverage reports concepts missing from either list, the share of attested
+// concepts of both lists and the number of concepts attested in both.
+func (l *Wordlist) PrintCoverage(other *Wordlist) {
+	var (
+		aligned1, aligned2, only1, only2 = Align(l, other)
+		numBoth                          int
+	)
+	if len(only1) > 0 {
+		log.Printf("WARNING: %d concept(s) are missing from %s: %s", len(only1), other.Group, describeWords(only1))
+	}
+	if len(only2) > 0 {
+		log.Printf("WARNING: %d concept(s) are missing from %s: %s", len(only2), l.Group, describeWords(only2))
+	}
+	for idx := range aligned1.List {
+		if aligned1.List[idx].Attested() && aligned2.List[idx].Attested() {
+			numBoth++
+		}
+	}
+
+	var msg string
+	for _, list := range []*Wordlist{l, other} {
+		var numAttested int
+		for _, word := range list.List {
+			if word.Attested() {
+				numAttested++
+			}
+		}
+		msg += fmt.Sprintf("Coverage of %s: %d / %d concept(s)", list.Group, numAttested, len(list.List))
+		if len(list.List) > 0 {
+			msg += fmt.Sprintf(" (%.1f%%)", 100*float64(numAttested)/float64(len(list.List)))
+		}
+		msg += "\n"
+	}
+	log.Printf("%sEffective N = %d (concepts attested in both lists)\n", msg, numBoth)
+}
+
+// alignForComparison aligns two wordlists, concepts that cannot be compared are
+// reported once per pair by PrintCoverage.
+func alignForComparison(list1, list2 *Wordlist) (aligned1, aligned2 *Wordlist, err error) {
+	aligned1, aligned2, _, _ = Align(list1, list2)
+	if len(aligned1.List) == 0 {
+		return nil, nil, errors.Errorf("%s and %s have no concepts in common", list1.Group, list2.Group)
+	}
+
+	return aligned1, aligned2, nil
+}
+
+func describeWords(words []*Word) string {
+	var out []string
+	for _, word := range words {
+		out = append(out, fmt.Sprintf("%d %s", word.SwadeshID, word.SwadeshWord))
+	}
+
+	return strings.Join(out, ", ")
+}
@@ -5,9 +5,8 @@ import (
 	"log"
 	"math/rand"
 	"runtime"
+	"strings"
 	"sync"
-
-	"github.com/pkg/errors"
 )
 
 var (
@@ -21,17 +20,15 @@ type Summary struct {
 	TotalCost   int
 }
 
-func CompareWordlists(list1, list2 *Wordlist, weights Weights, trials float64, verbose bool) (
+func CompareWordlists(list1, list2 *Wordlist, weights Weights, rule *MatchRule, trials float64, verbose bool) (
 	summary *Summary, err error) {
-	if len(list1.List) != len(list2.List) {
-		return nil, errors.Errorf("wordlists have different lengths: %d, %d",
-			len(list1.List), len(list2.List))
+	if list1, list2, err = alignForComparison(list1, list2); err != nil {
+		return nil, err
 	}
 
 	var (
-		baseScore, matched = list1.Compare(list2, weights)
-		baseCount          = len(matched)
-		baseResult         = &result{cost: baseScore, matches: matched}
+		baseScore, baseCount, matched = list1.Compare(list2, weights, rule)
+		baseResult                    = &result{cost: baseScore, count: baseCount, matches: matched}
 	)
 	baseResult.Print()
 
@@ -59,6 +56,7 @@ func CompareWordlists(list1, list2 *Wordlist, weights Weights, trials float64, v
 				list1:   list1,
 				list2:   &Wordlist{List: shuffled},
 				weights: weights,
+				rule:    rule,
 			}
 		}
 		wg.Done()
@@ -72,7 +70,7 @@ func CompareWordlists(list1, list2 *Wordlist, weights Weights, trials float64, v
 	go func(wg *sync.WaitGroup, results chan *result) {
 		for i := 0.; i < trials; i++ {
 			currResult := <-results
-			if len(currResult.matches) >= baseCount {
+			if currResult.count >= baseCount {
 				summary.TotalCounts++
 				if verbose {
 					currResult.Print()
@@ -81,7 +79,7 @@ func CompareWordlists(list1, list2 *Wordlist, weights Weights, trials float64, v
 			if currResult.cost >= baseScore {
 				summary.TotalCost++
 			}
-			summary.Counts[len(currResult.matches)]++
+			summary.Counts[currResult.count]++
 			summary.Costs[currResult.cost]++
 		}
 		wg.Done()
@@ -94,8 +92,8 @@ func CompareWordlists(list1, list2 *Wordlist, weights Weights, trials float64, v
 
 func worker(id int, jobs chan *job, results chan *result) {
 	for args := range jobs {
-		cost, matched := args.list1.Compare(args.list2, args.weights)
-		results <- &result{cost: cost, matches: matched}
+		cost, count, matched := args.list1.Compare(args.list2, args.weights, args.rule)
+		results <- &result{cost: cost, count: count, matches: matched}
 	}
 }
 
@@ -103,19 +101,34 @@ type job struct {
 	list1   *Wordlist
 	list2   *Wordlist
 	weights Weights
+	rule    *MatchRule
 }
 
 type result struct {
 	cost    float64
+	count   int
 	matches []string
 }
 
 func (r *result) Print() {
-	var msg string
-	for idx, match := range r.matches {
-		msg += fmt.Sprintf("Positive pair %d: %s\n", idx, match)
+	var (
+		msg, metathesisMsg         string
+		numPositive, numMetathesis int
+	)
+	for _, match := range r.matches {
+		if strings.HasPrefix(match, MetathesisMark) {
+			metathesisMsg += fmt.Sprintf("Metathesis pair %d: %s\n", numMetathesis,
+				strings.TrimPrefix(match, MetathesisMark))
+			numMetathesis++
+		} else {
+			msg += fmt.Sprintf("Positive pair %d: %s\n", numPositive, match)
+			numPositive++
+		}
 	}
 	log.Printf("%s", msg)
-	log.Printf("N = %d (number of positive pairs in the original list)\n", len(r.matches))
+	if numMetathesis > 0 {
+		log.Printf("%s", metathesisMsg)
+	}
+	log.Printf("N = %d (number of positive pairs in the original list)\n", r.count)
 	log.Printf("S = %f (cost of positive pairs in the original list)\n\n", r.cost)
 }
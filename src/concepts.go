@@ -0,0 +1,109 @@
+package src
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	conceptSourceIDCol = "id"
+	conceptGlossCol    = "gloss"
+	conceptIDCol       = "concepticon_id"
+	conceptCanonGloss  = "concepticon_gloss"
+)
+
+// Concept is a canonical concept of a concept list.
+type Concept struct {
+	ID    int
+	Gloss string
+}
+
+// ConceptMap maps concept IDs or glosses of a source list to canonical concepts.
+// It is read from a Concepticon-style TSV file with an ID and/or GLOSS column
+// for the source list and CONCEPTICON_ID and CONCEPTICON_GLOSS columns.
+type ConceptMap struct {
+	idToConcept    map[int]*Concept
+	glossToConcept map[string]*Concept
+}
+
+func NewConceptMap(mapPath string) (*ConceptMap, error) {
+	var out = &ConceptMap{
+		idToConcept:    map[int]*Concept{},
+		glossToConcept: map[string]*Concept{},
+	}
+	err := readTSV(mapPath, func(header tsvHeader) error {
+		if !header.has(conceptIDCol) || !header.has(conceptSourceIDCol) && !header.has(conceptGlossCol) {
+			return errors.New("header must contain ID or GLOSS and CONCEPTICON_ID columns")
+		}
+		return nil
+	}, func(row *tsvRow) error {
+		if len(row.cell(conceptIDCol)) == 0 {
+			return nil
+		}
+
+		conceptID, err := strconv.Atoi(row.cell(conceptIDCol))
+		if err != nil {
+			return err
+		}
+		var concept = &Concept{ID: conceptID, Gloss: row.cell(conceptCanonGloss)}
+
+		if sourceID := row.cell(conceptSourceIDCol); len(sourceID) > 0 {
+			id, err := strconv.Atoi(sourceID)
+			if err != nil {
+				return err
+			}
+			out.idToConcept[id] = concept
+		}
+		if gloss := row.cell(conceptGlossCol); len(gloss) > 0 {
+			out.glossToConcept[normalizeGloss(gloss)] = concept
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Lookup returns the canonical concept of a source ID, or of a gloss if the ID
+// is not in the map.
+func (m *ConceptMap) Lookup(id int, gloss string) (*Concept, bool) {
+	if concept, ok := m.idToConcept[id]; ok {
+		return concept, true
+	}
+	concept, ok := m.glossToConcept[normalizeGloss(gloss)]
+	return concept, ok
+}
+
+func normalizeGloss(gloss string) string {
+	return strings.ToLower(strings.Join(strings.Fields(gloss), " "))
+}
+
+// mergeConcepts sorts words by Swadesh ID and merges words of the same concept.
+func (l *Wordlist) mergeConcepts() {
+	sort.SliceStable(l.List, func(i, j int) bool {
+		return l.List[i].SwadeshID < l.List[j].SwadeshID
+	})
+
+	var merged []*Word
+	for _, word := range l.List {
+		if last := len(merged) - 1; last >= 0 && merged[last].SwadeshID == word.SwadeshID {
+			merged[last].appendForms(word)
+		} else {
+			merged = append(merged, word)
+		}
+	}
+	l.List = merged
+}
+
+func reportUnmappedConcepts(listsPath string, unmapped []string) {
+	if len(unmapped) > 0 {
+		log.Printf("WARNING: %d concept(s) of %s are not in the concept map and were skipped: %s",
+			len(unmapped), listsPath, strings.Join(unmapped, ", "))
+	}
+}
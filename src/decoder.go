@@ -1,9 +1,13 @@
 package src
 
 import (
+	"fmt"
 	"log"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
@@ -16,183 +20,304 @@ const (
 	groupsStartCol = 2
 )
 
-var (
-	Laryngeals        string
-	VowelsAndFeatures string
-	Glides            string
-	LabialGlides      string
+const (
+	laryngealsClass   = "Laryngeals"
+	vowelsClass       = "Vowels and features"
+	glidesClass       = "Glides"
+	labialGlidesClass = "Labial glides"
 )
 
+// commentRegexp matches comments in forms.
+var commentRegexp = regexp.MustCompile("\\(.*\\)")
+
+// optionalRegexp matches optional segments left in forms by stripComments.
+var optionalRegexp = regexp.MustCompile("\\(([^()]*)\\)")
+
+// SoundClass is a single row of a sound model: every member sound is decoded as ID.
+// Members are single runes, Segments are multi-rune sounds (affricates, digraphs).
+type SoundClass struct {
+	ID       string
+	Name     string
+	Members  string
+	Segments []string
+}
+
 type SoundClassesDecoder struct {
-	SoundToClassID map[rune]string
+	SoundToClassID   map[rune]string
+	SegmentToClassID map[string]string
+	// Profiles maps language column names to orthography profiles applied before decoding.
+	Profiles map[string]*OrthographyProfile
+	// Affixes maps language column names to affixes stripped from forms before decoding.
+	Affixes map[string]*AffixList
+	// Concepts maps concepts of wordlist files to canonical concepts, nil to use the IDs as is.
+	Concepts *ConceptMap
+	// Strict makes Decode fail if a form contains characters missing from the sound model.
+	Strict bool
+	// Similarity holds class-to-class similarity scores, nil if the model has none.
+	Similarity    *SimilarityMatrix
+	maxSegmentLen int
+	normalization NormalizationForm
+	foldCase      bool
+	classNameToID map[string]string
+	// rules are the root extraction rules loaded with the sound model, see SetRules.
+	rules         *RootRules
+	positionRules map[string]*resolvedRule
+	paddingID     string
+
+	// unknownSounds is shared by concurrent Decode calls.
+	mu            sync.Mutex
+	unknownSounds map[rune]*UnknownSound
 }
 
 func NewSoundClassesDecoder(classesPath string) (*SoundClassesDecoder, error) {
-	out := &SoundClassesDecoder{
-		SoundToClassID: map[rune]string{},
-	}
-
 	classesFile, err := xlsx.OpenFile(classesPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to read %s", classesPath)
 	}
 
-	if len(classesFile.Sheets) != 1 {
-		return nil, errors.New("single sheet is expected")
+	if len(classesFile.Sheets) < 1 {
+		return nil, errors.New("at least one sheet is expected")
+	}
+
+	var (
+		rules           = DefaultRootRules()
+		similaritySheet *xlsx.Sheet
+	)
+	for _, sheet := range classesFile.Sheets[1:] {
+		switch sheet.Name {
+		case rulesSheetName:
+			if rules, err = parseRootRules(sheet); err != nil {
+				return nil, errors.Wrapf(err, "failed to read rules from %s", classesPath)
+			}
+		case similaritySheetName:
+			similaritySheet = sheet
+		default:
+			return nil, errors.Errorf("unexpected sheet %q (only %q and %q sheets may follow sound classes)",
+				sheet.Name, rulesSheetName, similaritySheetName)
+		}
 	}
 
+	var classes []SoundClass
 	for idx, row := range classesFile.Sheets[0].Rows {
 		if len(row.Cells) < 2 {
 			return nil, errors.Errorf("row %d has less than 2 cells", idx)
 		}
-		var (
-			classMembers = strings.TrimSpace(row.Cells[0].String())
-			className    = strings.TrimSpace(row.Cells[1].String())
-			classID      = classMembers[:1]
-		)
-		switch className {
-		case "Laryngeals":
-			Laryngeals = classID
-		case "Vowels and features":
-			VowelsAndFeatures = classID
-		case "Glides":
-			Glides = classID
-		case "Labial glides":
-			LabialGlides = classID
+		var classMembers = strings.TrimSpace(row.Cells[0].String())
+		class := SoundClass{
+			ID:      classMembers[:1],
+			Name:    strings.TrimSpace(row.Cells[1].String()),
+			Members: classMembers,
 		}
-
-		for _, classMember := range classMembers {
-			out.SoundToClassID[classMember] = classID
+		// The optional third column lists space-separated multi-rune segments.
+		if len(row.Cells) > 2 {
+			class.Segments = strings.Fields(row.Cells[2].String())
 		}
+		classes = append(classes, class)
 	}
 
-	if len(Laryngeals) < 0 {
-		log.Println("WARNING: Laryngeals not found, this might lead to incorrect behavior")
+	out, err := NewSoundClassesDecoderFromClasses(classes, rules)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read rules from %s", classesPath)
 	}
-	if len(VowelsAndFeatures) < 0 {
-		log.Println("WARNING: VowelsAndFeatures not found, this might lead to incorrect behavior")
+	if similaritySheet != nil {
+		if out.Similarity, err = parseSimilarity(similaritySheet, out.classNameToID); err != nil {
+			return nil, errors.Wrapf(err, "failed to read similarity from %s", classesPath)
+		}
 	}
-	if len(Glides) < 0 {
-		log.Println("WARNING: Glides not found, this might lead to incorrect behavior")
+
+	return out, nil
+}
+
+func NewSoundClassesDecoderFromClasses(classes []SoundClass, rules *RootRules) (*SoundClassesDecoder, error) {
+	out := &SoundClassesDecoder{
+		SoundToClassID:   map[rune]string{},
+		SegmentToClassID: map[string]string{},
+		classNameToID:    map[string]string{},
+		unknownSounds:    map[rune]*UnknownSound{},
 	}
-	if len(LabialGlides) < 0 {
-		log.Println("WARNING: LabialGlides not found, this might lead to incorrect behavior")
+
+	for _, class := range classes {
+		out.classNameToID[class.Name] = class.ID
+		for _, classMember := range class.Members {
+			out.SoundToClassID[classMember] = class.ID
+		}
+
+		for _, segment := range class.Segments {
+			out.SegmentToClassID[segment] = class.ID
+			if segmentLen := utf8.RuneCountInString(segment); segmentLen > out.maxSegmentLen {
+				out.maxSegmentLen = segmentLen
+			}
+		}
+	}
+
+	if err := out.SetRules(rules); err != nil {
+		return nil, err
 	}
 
 	return out, nil
 }
 
-func (d *SoundClassesDecoder) Decode(listsPath string, selected map[string]bool) ([]*Wordlist, error) {
-	groupToWordlist := map[string]*Wordlist{}
+// Rules returns a copy of the root extraction rules of the decoder.
+func (d *SoundClassesDecoder) Rules() *RootRules {
+	return d.rules.copy()
+}
 
-	wordlistsFile, err := xlsx.OpenFile(listsPath)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read %s", listsPath)
+// SetRules replaces the root extraction rules of the decoder. The rules are
+// copied, so changing them later has no effect until SetRules is called again.
+// It must not be called while forms are being decoded.
+func (d *SoundClassesDecoder) SetRules(rules *RootRules) error {
+	// Markers flagging variants would hide sounds of the model.
+	for _, marker := range rules.DoubtMarkers + rules.LoanMarkers {
+		if _, ok := d.SoundToClassID[marker]; ok {
+			return errors.Errorf("marker %q is a sound of the model", marker)
+		}
 	}
 
-	if len(wordlistsFile.Sheets) != 1 {
-		return nil, errors.New("single sheet is expected")
-	}
+	d.rules = rules.copy()
+	d.positionRules, d.paddingID = resolveRules(d.rules, d.classNameToID)
 
-	if len(wordlistsFile.Sheets[0].Rows) < 2 {
-		return nil, errors.New("document is malformed: less than 2 rows is present")
+	return nil
+}
+
+// ClassID returns the ID of a class of the sound model by its name.
+func (d *SoundClassesDecoder) ClassID(className string) (string, bool) {
+	classID, ok := d.classNameToID[className]
+	return classID, ok
+}
+
+// Decode reads the wordlists of the languages chosen by the selector, nil selects all languages.
+func (d *SoundClassesDecoder) Decode(listsPath string, selector *LanguageSelector) ([]*Wordlist, error) {
+	groupToWordlist := map[string]*Wordlist{}
+	profiles := d.normalizedProfiles()
+
+	rows, layout, err := openWordlists(listsPath)
+	if err != nil {
+		return nil, err
 	}
 
 	var (
 		sortedGroupNames []string
-		headerRow        = wordlistsFile.Sheets[0].Rows[0].Cells
-		allSelected      bool
+		languages        []*languageColumn
+		found            = map[string]bool{}
 	)
-	if selected == nil {
-		selected = map[string]bool{}
-		allSelected = true
-	}
-
-	var maxGroupIdx = groupsStartCol
-	for groupIdx := groupsStartCol; groupIdx < len(headerRow); groupIdx++ {
-		var groupName = headerRow[groupIdx].String()
-		if len(groupName) > 0 {
-			maxGroupIdx++
-		}
-
-		if strings.HasSuffix(groupName, "NUM") {
-			continue
+	for _, language := range layout.languages {
+		found[language.name] = true
+		if selector.Selected(language.name) {
+			languages = append(languages, language)
+			if _, ok := groupToWordlist[language.name]; !ok {
+				sortedGroupNames = append(sortedGroupNames, language.name)
+				groupToWordlist[language.name] = &Wordlist{Group: language.name}
+			}
 		}
+	}
 
-		if _, ok := selected[groupName]; allSelected || ok {
-			selected[groupName] = true
-			sortedGroupNames = append(sortedGroupNames, groupName)
-			groupToWordlist[groupName] = &Wordlist{Group: groupName}
-		}
+	if missing := selector.missing(found); len(missing) > 0 {
+		sort.Strings(missing)
+		log.Printf("WARNING: language(s) not found in %s: %s", listsPath, strings.Join(missing, ", "))
 	}
 
-	var lastSwadeshID = 0
-	for idx := 1; idx < len(wordlistsFile.Sheets[0].Rows); idx++ {
-		row := wordlistsFile.Sheets[0].Rows[idx].Cells
-		swadeshID, err := row[swadeshIDCol].Int()
+	var (
+		lastSwadeshID      = 0
+		numUnknown         int
+		unmapped           []string
+		swadeshWordCleaner = regexp.MustCompile("[0-9]|\\[.*\\]")
+	)
+	for idx := 1; idx < len(rows); idx++ {
+		row := rows[idx].Cells
+		swadeshID, err := cellInt(row, layout.idCol)
 		if err != nil {
-			return nil, errors.Wrapf(err, "row %d, column %d", idx, swadeshIDCol)
+			return nil, errors.Wrapf(err, "row %d, column %d", idx, layout.idCol)
 		}
 
-		var swadeshWord = strings.TrimSpace(row[swadeshWordCol].String())
-		for groupIdx := groupsStartCol; groupIdx < maxGroupIdx; groupIdx++ {
-			if _, ok := selected[headerRow[groupIdx].String()]; !ok {
+		var swadeshWord = swadeshWordCleaner.ReplaceAllString(strings.TrimSpace(cellString(row, layout.conceptCol)), "")
+		var conceptID = swadeshID
+		if d.Concepts != nil {
+			concept, ok := d.Concepts.Lookup(swadeshID, swadeshWord)
+			if !ok {
+				if lastSwadeshID != swadeshID {
+					unmapped = append(unmapped, fmt.Sprintf("%d %s", swadeshID, strings.TrimSpace(swadeshWord)))
+				}
+				lastSwadeshID = swadeshID
 				continue
 			}
-			// Some group columns are followed by a column containing cognition indices.
-			var (
-				skipColumn bool
-				ignoreForm bool
-			)
-			if groupIdx+1 < maxGroupIdx {
-				if maybeCognitiveIndex, err := row[groupIdx+1].Int(); err == nil {
-					skipColumn = true
-					if maybeCognitiveIndex < 0 {
-						ignoreForm = true
-					}
-				}
+			conceptID = concept.ID
+			if len(concept.Gloss) > 0 {
+				swadeshWord = concept.Gloss
 			}
+		}
 
+		for _, language := range languages {
+			// A negative cognate index excludes the form from comparison.
 			var (
-				groupName          = headerRow[groupIdx].String()
-				swadeshWordCleaner = regexp.MustCompile("[0-9]|\\[.*\\]")
+				ignoreForm   bool
+				cognateIndex int
 			)
+			if index, err := cellInt(row, language.cognateCol); err == nil {
+				ignoreForm, cognateIndex = index < 0, index
+			}
+
+			var groupName = language.name
 			// Start a new Swadesh word.
 			if lastSwadeshID != swadeshID {
 				word := &Word{
 					Group:       groupName,
-					SwadeshID:   swadeshID,
-					SwadeshWord: swadeshWordCleaner.ReplaceAllString(swadeshWord, ""),
+					SwadeshID:   conceptID,
+					SwadeshWord: swadeshWord,
 				}
 				groupToWordlist[groupName].List = append(groupToWordlist[groupName].List, word)
 			}
 
-			var form = strings.TrimSpace(row[groupIdx].String())
+			var form = strings.TrimSpace(cellString(row, language.col))
 			if len(form) < 1 {
-				if skipColumn {
-					groupIdx++
-				}
 				continue
 			}
 
-			var clean, decoded = d.decodeForm(form)
+			// Profiles apply to normalized forms.
+			var source = d.normalize(form)
+			if profile, ok := profiles[groupName]; ok {
+				source = profile.Apply(source)
+			}
+
+			var parsed = d.parseForm(source, d.Affixes[groupName])
 			if !ignoreForm {
 				lastWord := groupToWordlist[groupName].List[len(groupToWordlist[groupName].List)-1]
+				numUnknown += d.recordUnknownSounds(parsed.clean, &UnknownExample{
+					Form:        form,
+					Group:       groupName,
+					SwadeshID:   lastWord.SwadeshID,
+					SwadeshWord: lastWord.SwadeshWord,
+				})
+				for range parsed.clean {
+					lastWord.Sources = append(lastWord.Sources, len(lastWord.Forms))
+				}
 				lastWord.Forms = append(lastWord.Forms, form)
-				lastWord.CleanForms = append(lastWord.CleanForms, clean...)
-				lastWord.DecodedForms = append(lastWord.DecodedForms, decoded...)
-			}
-
-			if skipColumn {
-				groupIdx++
+				lastWord.CleanForms = append(lastWord.CleanForms, parsed.clean...)
+				lastWord.DecodedForms = append(lastWord.DecodedForms, parsed.decoded...)
+				lastWord.Doubtful = append(lastWord.Doubtful, parsed.doubtful...)
+				lastWord.Affixes = append(lastWord.Affixes, parsed.affixes...)
+				lastWord.Loans = append(lastWord.Loans, parsed.loans...)
+				for _, cleanForm := range parsed.clean {
+					lastWord.Segments = append(lastWord.Segments, d.knownSegments(cleanForm))
+					lastWord.Cognates = append(lastWord.Cognates, cognateIndex)
+				}
 			}
 		}
 
 		lastSwadeshID = swadeshID
 	}
 
+	if d.Strict && numUnknown > 0 {
+		return nil, errors.Errorf("%d character(s) in %s are not in the sound model", numUnknown, listsPath)
+	}
+
 	var out []*Wordlist
+	if d.Concepts != nil {
+		reportUnmappedConcepts(listsPath, unmapped)
+	}
 	for _, groupName := range sortedGroupNames {
+		if d.Concepts != nil {
+			groupToWordlist[groupName].mergeConcepts()
+		}
 		if len(groupToWordlist[groupName].List) > 0 {
 			out = append(out, groupToWordlist[groupName])
 		}
@@ -201,72 +326,195 @@ func (d *SoundClassesDecoder) Decode(listsPath string, selected map[string]bool)
 	return out, nil
 }
 
+// openWordlists reads the rows of a wordlists file and finds its columns.
+func openWordlists(listsPath string) ([]*xlsx.Row, *columnLayout, error) {
+	wordlistsFile, err := xlsx.OpenFile(listsPath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read %s", listsPath)
+	}
+
+	if len(wordlistsFile.Sheets) < 1 {
+		return nil, nil, errors.New("single sheet is expected")
+	}
+	for _, sheet := range wordlistsFile.Sheets[1:] {
+		if sheet.Name != schemaSheetName {
+			return nil, nil, errors.Errorf("single sheet is expected (besides a %q sheet)", schemaSheetName)
+		}
+	}
+
+	var rows = wordlistsFile.Sheets[0].Rows
+	if len(rows) < 2 {
+		return nil, nil, errors.New("document is malformed: less than 2 rows is present")
+	}
+
+	var headerRow []string
+	for _, cell := range rows[0].Cells {
+		headerRow = append(headerRow, cell.String())
+	}
+
+	schema, err := findSchema(listsPath, wordlistsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var layout = guessLayout(headerRow, rows[1:])
+	if schema != nil {
+		if layout, err = schema.layout(headerRow); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to apply schema to %s", listsPath)
+		}
+	}
+
+	return rows, layout, nil
+}
+
 func (d *SoundClassesDecoder) decodeForm(form string) (clean []string, decoded []string) {
-	r, _ := regexp.Compile("\\(.*\\)")
-	form = r.ReplaceAllString(form, "")
-	form = strings.Replace(form, "*", "", -1)
+	var parsed = d.parseForm(form, nil)
+	return parsed.clean, parsed.decoded
+}
+
+// parsedForm is a form split into clean variants and decoded roots. Doubtful is set
+// for every clean variant marked as uncertain, affixes hold the affixes stripped
+// from every clean variant, Loans are the variants marked as borrowed, which are
+// left out of clean and decoded.
+type parsedForm struct {
+	clean    []string
+	decoded  []string
+	doubtful []bool
+	affixes  []string
+	loans    []string
+}
+
+// parseForm splits a form into variants, affixes of the list are stripped from
+// every variant before it is cleansed.
+func (d *SoundClassesDecoder) parseForm(form string, affixes *AffixList) (out *parsedForm) {
+	out = &parsedForm{}
+
+	form = stripComments(d.normalize(form))
+	form = strings.Map(func(char rune) rune {
+		if strings.ContainsRune(d.rules.StripMarkers, char) {
+			return -1
+		}
+		return char
+	}, form)
+
+	var variants = []string{form}
+	for _, marker := range d.rules.VariantMarkers {
+		if strings.ContainsRune(form, marker) {
+			variants = strings.Split(form, string(marker))
+			break
+		}
+	}
+
+	for _, variant := range variants {
+		variant, flags := d.variantFlags(variant)
+		var (
+			isDoubtful = strings.ContainsAny(flags, d.rules.DoubtMarkers)
+			isLoan     = strings.ContainsAny(flags, d.rules.LoanMarkers)
+		)
+
+		stem, stripped := affixes.Strip(variant)
+		var seen = map[string]bool{}
+		for _, expanded := range expandOptional(stem) {
+			var cleanForm = d.cleanseForm(expanded)
+			if seen[cleanForm] {
+				continue
+			}
+			seen[cleanForm] = true
+
+			if isLoan {
+				out.loans = append(out.loans, cleanForm)
+				continue
+			}
+			out.clean = append(out.clean, cleanForm)
+			out.doubtful = append(out.doubtful, isDoubtful)
+			out.affixes = append(out.affixes, strings.Join(stripped, " "))
+		}
+	}
+	out.decoded = d.decodeRoots(out.clean)
+
+	return out
+}
+
+// variantFlags splits the doubt and loan markers off a variant. Markers only flag
+// a variant at its start, as in "?kar", or as a separate word, as in "kar < Turk.",
+// where the rest of the variant is a note; elsewhere they are kept as characters.
+func (d *SoundClassesDecoder) variantFlags(variant string) (stem, flags string) {
+	var markers = d.rules.DoubtMarkers + d.rules.LoanMarkers
+	if len(markers) == 0 {
+		return variant, ""
+	}
+	var isMarker = func(char rune) bool {
+		return strings.ContainsRune(markers, char)
+	}
 
-	if strings.Contains(form, "~") {
-		clean = append(clean, strings.Split(form, "~")...)
-	} else if strings.Contains(form, "/") {
-		clean = append(clean, strings.Split(form, "/")...)
-	} else {
-		clean = append(clean, form)
+	variant = strings.TrimSpace(variant)
+	if stem = strings.TrimLeftFunc(variant, func(char rune) bool {
+		return isMarker(char) || unicode.IsSpace(char)
+	}); len(stem) < len(variant) {
+		flags = strings.Map(func(char rune) rune {
+			if isMarker(char) {
+				return char
+			}
+			return -1
+		}, variant[:len(variant)-len(stem)])
 	}
 
-	for idx, form := range clean {
-		clean[idx] = d.cleanseForm(form)
+	var words = strings.Fields(stem)
+	for idx := 1; idx < len(words); idx++ {
+		if len(strings.TrimFunc(words[idx], isMarker)) == 0 {
+			return strings.Join(words[:idx], " "), flags + words[idx]
+		}
 	}
 
+	return stem, flags
+}
+
+func (d *SoundClassesDecoder) decodeRoots(clean []string) (decoded []string) {
 	decoded = make([]string, len(clean))
 	for idx, word := range clean {
 		var (
 			decodedForm string
-			charIdx     int
+			segments    = d.segment(word)
 		)
-		for _, char := range word {
-			if classID, ok := d.SoundToClassID[char]; ok {
-				switch classID {
-				case Laryngeals, VowelsAndFeatures:
-					if len(decodedForm) < 2 && (charIdx == 0 || charIdx >= utf8.RuneCountInString(word)-1) {
-						decodedForm += Laryngeals
-					}
-				case Glides, LabialGlides:
-					if len(decodedForm) < 2 {
-						if charIdx == 0 {
-							decodedForm += classID
-						}
-						if charIdx >= utf8.RuneCountInString(word)-1 {
-							decodedForm += Laryngeals
-						}
-					}
-				default:
-					decodedForm += classID
-				}
+		for segmentIdx, segment := range segments {
+			if len(segment.classID) == 0 {
+				continue
 			}
 
-			charIdx++
+			rule, ok := d.positionRules[segment.classID]
+			if !ok {
+				decodedForm += segment.classID
+				continue
+			}
+
+			if len(decodedForm) >= d.rules.Length {
+				continue
+			}
+			for _, action := range rule.actions(segmentIdx == 0, segmentIdx >= len(segments)-1) {
+				if action.keep {
+					decodedForm += segment.classID
+				} else {
+					decodedForm += action.classID
+				}
+			}
 		}
 
-		if len(decodedForm) == 1 {
-			decodedForm += VowelsAndFeatures
+		for len(decodedForm) > 0 && len(decodedForm) < d.rules.Length && len(d.paddingID) > 0 {
+			decodedForm += d.paddingID
 		}
 
 		decoded[idx] = decodedForm
 	}
 
-	return clean, decoded
+	return decoded
 }
 
-func (d SoundClassesDecoder) cleanseForm(form string) (out string) {
+func (d *SoundClassesDecoder) cleanseForm(form string) (out string) {
 	form = strings.TrimSpace(form)
 	for _, char := range form {
-		switch char {
-		case '=':
+		switch {
+		case strings.ContainsRune(d.rules.ResetMarkers, char):
 			out = ""
-		case '-':
-			return strings.TrimSpace(out)
-		case ' ':
+		case strings.ContainsRune(d.rules.CutMarkers, char):
 			return strings.TrimSpace(out)
 		default:
 			out += string(char)
@@ -275,3 +523,63 @@ func (d SoundClassesDecoder) cleanseForm(form string) (out string) {
 
 	return strings.TrimSpace(out)
 }
+
+// stripComments removes bracketed comments from a form. Brackets right after a
+// sound holding nothing but sounds, as in "ka(r)", are optional segments and are
+// kept; everything else in brackets is a comment.
+func stripComments(form string) string {
+	var (
+		out   []rune
+		runes = []rune(form)
+	)
+	for idx := 0; idx < len(runes); idx++ {
+		if runes[idx] != '(' {
+			out = append(out, runes[idx])
+			continue
+		}
+
+		var end, depth = idx, 0
+		for ; end < len(runes); end++ {
+			if runes[end] == '(' {
+				depth++
+			} else if runes[end] == ')' {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		if end == len(runes) {
+			// An unclosed bracket is left as is.
+			out = append(out, runes[idx:]...)
+			break
+		}
+
+		var content = string(runes[idx+1 : end])
+		if len(out) > 0 && !unicode.IsSpace(out[len(out)-1]) &&
+			len(content) > 0 && !strings.ContainsAny(content, "()* \t") {
+			out = append(out, runes[idx:end+1]...)
+		}
+		idx = end
+	}
+
+	return string(out)
+}
+
+// expandOptional returns the variants of a form with optional segments, longest first:
+// "ka(r)" gives "kar" and "ka".
+func expandOptional(form string) []string {
+	var loc = optionalRegexp.FindStringSubmatchIndex(form)
+	if loc == nil {
+		return []string{form}
+	}
+
+	var out []string
+	for _, rest := range expandOptional(form[loc[1]:]) {
+		out = append(out, form[:loc[0]]+form[loc[2]:loc[3]]+rest)
+	}
+	for _, rest := range expandOptional(form[loc[1]:]) {
+		out = append(out, form[:loc[0]]+rest)
+	}
+
+	return out
+}
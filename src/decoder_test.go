@@ -1,9 +1,15 @@
 package src
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/tealeg/xlsx"
 )
 
 func TestSoundClassesDecoder_Decode(t *testing.T) {
@@ -565,6 +571,16 @@ func TestSoundClassesDecoder_Decode(t *testing.T) {
 			"pǝ̀r-ʔǝ́y":         {"PR"},
 			"hʌ́y":              {"HH"},
 		},
+		// Modifier letters and combining diacritics attach to their base.
+		{
+			"*kʷi":     {"KH"},
+			"*gʰʷer-":  {"KR"},
+			"*dʰengʷ-": {"TNK"},
+			"*kʸlew":   {"KL"},
+			"*tə̀":     {"TH"},
+			"*pə̃rʲə̀": {"PR"},
+			"*ʷa":      {"HH"},
+		},
 	}
 
 	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
@@ -577,3 +593,659 @@ func TestSoundClassesDecoder_Decode(t *testing.T) {
 		}
 	}
 }
+
+func TestNewSoundClassesDecoderFromPreset(t *testing.T) {
+	testCases := map[string]map[string][]string{
+		"dolgopolsky": {
+			"*kàm-":       {"KM"},
+			"*yak":        {"JK"},
+			"*ič":         {"HS"},
+			"*kulga-k":    {"KRK"},
+			"*tí":         {"TH"},
+			"*tsar":       {"SR"},
+			"*bi ~ *mi-n": {"PH", "MH"},
+		},
+		"sca": {
+			"*kàm-":   {"KM"},
+			"*ič":     {"HC"},
+			"*xolga-": {"GLK"},
+			"*tí":     {"TH"},
+			// The grave accent attaches to ə, which is then final and becomes a laryngeal.
+			"*kə̀-":  {"KH"},
+			"*ǯalu-": {"CL"},
+			"*=mV":   {"MV"},
+			"*tʃana": {"CN"},
+			"*dʒir":  {"CR"},
+		},
+		"asjp": {
+			"*kàm-":   {"km"},
+			"*ič":     {"hC"},
+			"*baːr-ɨ": {"br"},
+			"*tí":     {"th"},
+			"*kə̀-":   {"kh"},
+			"*=mV":    {"mV"},
+			"*tsar":   {"cr"},
+			"*tʃʰi":   {"Ch"},
+			"*dʒa":    {"jh"},
+			"*ŋaːla":  {"Nl"},
+		},
+	}
+
+	for model, testCase := range testCases {
+		decoder, err := NewSoundClassesDecoderFromPreset(model)
+		assert.NoError(t, err)
+
+		for forms, expected := range testCase {
+			_, decoded := decoder.decodeForm(forms)
+			assert.Equal(t, expected, decoded, "model: %s, forms: %v", model, forms)
+		}
+	}
+
+	_, err := NewSoundClassesDecoderFromPreset("unknown")
+	assert.Error(t, err)
+}
+
+func TestSoundModelsCoverIPAConsonants(t *testing.T) {
+	// Pulmonic consonants of the IPA chart and the affricate ligatures.
+	const consonants = "pbtdʈɖcɟkgqɢʔmɱnɳɲŋɴʙrʀⱱɾɽɸβfvθðszʃʒʂʐçʝxɣχʁħʕhɦɬɮʋɹɻjɰlɭʎʟʦʣʧʤ"
+	for _, model := range SoundModelNames() {
+		decoder, err := NewSoundClassesDecoderFromPreset(model)
+		assert.NoError(t, err)
+		for _, consonant := range consonants {
+			_, ok := decoder.SoundToClassID[consonant]
+			assert.True(t, ok, "model: %s, consonant: %c", model, consonant)
+		}
+	}
+}
+
+func TestSoundClassesDecoder_Segment(t *testing.T) {
+	decoder, err := NewSoundClassesDecoderFromPreset("sca")
+	assert.NoError(t, err)
+
+	testCases := map[string][]segment{
+		"tʃʰa": {{"tʃʰ", "C"}, {"a", "V"}},
+		"kʷə̀": {{"kʷ", "K"}, {"ə̀", "V"}},
+		"tsa":  {{"ts", "C"}, {"a", "V"}},
+		"tVs":  {{"t", "T"}, {"V", ""}, {"s", "S"}},
+		"ʰta":  {{"ʰ", ""}, {"t", "T"}, {"a", "V"}},
+	}
+
+	for form, expected := range testCases {
+		assert.Equal(t, expected, decoder.segment(form), "form: %s", form)
+	}
+}
+
+func TestOrthographyProfile(t *testing.T) {
+	profile, err := NewOrthographyProfile("../data/profiles/Proto-Uralic.tsv")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "**ɕarma", profile.Apply("**sʸarma"))
+	assert.Equal(t, "*kaðma", profile.Apply("*kaðʸma"))
+	assert.Equal(t, "*ratʃæ", profile.Apply("*račä"))
+
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	decoder.Profiles, err = LoadOrthographyProfiles("../data/profiles")
+	assert.NoError(t, err)
+
+	wordlists, err := decoder.Decode("../data/wordlists.xlsx", SelectLanguages("Proto-Uralic"))
+	assert.NoError(t, err)
+	assert.Len(t, wordlists, 1)
+
+	bird := wordlists[0].List[1]
+	assert.Equal(t, 6, bird.SwadeshID)
+	assert.Equal(t, "**sʸarma", bird.Forms[0])
+	assert.Equal(t, "ɕarma", bird.CleanForms[0])
+	assert.Equal(t, "CRM", bird.DecodedForms[0])
+
+	_, decoded := decoder.decodeForm(profile.Apply("*račä"))
+	assert.Equal(t, []string{"RC"}, decoded)
+}
+
+func TestSoundClassesDecoder_UnknownSounds(t *testing.T) {
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+
+	_, err = decoder.Decode("../data/wordlists.xlsx", nil)
+	assert.NoError(t, err)
+
+	unknowns := decoder.UnknownSounds()
+	assert.Len(t, unknowns, 1)
+	assert.Equal(t, 'V', unknowns[0].Char)
+	assert.Equal(t, 36, unknowns[0].Count)
+	assert.Equal(t, &UnknownExample{
+		Form:        "**nVrmV-",
+		Group:       "Proto-Uralic",
+		SwadeshID:   6,
+		SwadeshWord: "bird",
+	}, unknowns[0].Examples[0])
+	assert.Len(t, unknowns[0].Examples, maxUnknownExamples)
+
+	decoder.Strict = true
+	_, err = decoder.Decode("../data/wordlists.xlsx", nil)
+	assert.Error(t, err)
+}
+
+func TestSoundClassesDecoder_Markup(t *testing.T) {
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	prefix, err := newAffixRule("mu-", "")
+	assert.NoError(t, err)
+	var affixes = &AffixList{rules: []*affixRule{prefix}}
+
+	testCases := []struct {
+		form     string
+		expected *parsedForm
+	}{
+		{"ka(r)", &parsedForm{clean: []string{"kar", "ka"}, decoded: []string{"KR", "KH"}, doubtful: []bool{false, false},
+			affixes: []string{"", ""}}},
+		{"ka(r)ma(n)", &parsedForm{
+			clean:    []string{"karman", "karma", "kaman", "kama"},
+			decoded:  []string{"KRMN", "KRM", "KMN", "KM"},
+			doubtful: []bool{false, false, false, false},
+			affixes:  []string{"", "", "", ""},
+		}},
+		{"kar (dial.)", &parsedForm{clean: []string{"kar"}, decoded: []string{"KR"}, doubtful: []bool{false}, affixes: []string{""}}},
+		{"(see ka(r)) tu", &parsedForm{clean: []string{"tu"}, decoded: []string{"TH"}, doubtful: []bool{false}, affixes: []string{""}}},
+		{"*ma(l)-ti", &parsedForm{clean: []string{"mal", "ma"}, decoded: []string{"ML", "MH"}, doubtful: []bool{false, false},
+			affixes: []string{"", ""}}},
+		{"?mal ~ kar", &parsedForm{clean: []string{"mal", "kar"}, decoded: []string{"ML", "KR"}, doubtful: []bool{true, false},
+			affixes: []string{"", ""}}},
+		{"mal ? ~ kar", &parsedForm{clean: []string{"mal", "kar"}, decoded: []string{"ML", "KR"}, doubtful: []bool{true, false},
+			affixes: []string{"", ""}}},
+		{"mal ~ <kar", &parsedForm{clean: []string{"mal"}, decoded: []string{"ML"}, doubtful: []bool{false}, affixes: []string{""},
+			loans: []string{"kar"}}},
+		{"< kar", &parsedForm{decoded: []string{}, loans: []string{"kar"}}},
+		{"kar < Turk.", &parsedForm{decoded: []string{}, loans: []string{"kar"}}},
+		{"!kar", &parsedForm{decoded: []string{}, loans: []string{"kar"}}},
+		// Markers inside a variant are not flags.
+		{"ka?r", &parsedForm{clean: []string{"ka?r"}, decoded: []string{"KR"}, doubtful: []bool{false}, affixes: []string{""}}},
+		{"mu-kar ~ ?mu-tu", &parsedForm{clean: []string{"kar", "tu"}, decoded: []string{"KR", "TH"}, doubtful: []bool{false, true},
+			affixes: []string{"mu-", "mu-"}}},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, decoder.parseForm(testCase.form, affixes), "form: %v", testCase.form)
+	}
+
+	clean, decoded := decoder.decodeForm("ka(r)")
+	assert.Equal(t, []string{"kar", "ka"}, clean)
+	assert.Equal(t, []string{"KR", "KH"}, decoded)
+}
+
+func TestAffixes(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "affixes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	var affixesPath = filepath.Join(tmpDir, "affixes.tsv")
+	assert.NoError(t, ioutil.WriteFile(affixesPath, []byte(
+		"LANGUAGE\tAFFIX\tPATTERN\n"+
+			"Swahili\t\t^(mu|m|wa|ki|vi)-?\n"+
+			"Swahili\t-ni\t\n"+
+			"Hebrew\tha-\t\n"+
+			"Hebrew\tma-\t\n"), 0666))
+	affixes, err := LoadAffixes(affixesPath)
+	assert.NoError(t, err)
+	assert.Len(t, affixes, 2)
+
+	testCases := []struct {
+		language, form, stem string
+		affixes              []string
+	}{
+		{"Swahili", "mu-ntu", "ntu", []string{"mu-"}},
+		{"Swahili", "kitabu", "tabu", []string{"ki-"}},
+		{"Swahili", "nyumba-ni", "nyumba", []string{"-ni"}},
+		{"Swahili", "nyumbani", "nyumbani", nil},
+		{"Swahili", "mti-ni", "ti", []string{"m-", "-ni"}},
+		{"Swahili", "mu", "mu", nil},
+		{"Hebrew", "ha-bayit", "bayit", []string{"ha-"}},
+		{"Hebrew", "bayit", "bayit", nil},
+		{"Hebrew", "har", "har", nil},
+		{"Hebrew", "hamar", "hamar", nil},
+		{"Hebrew", "mama", "mama", nil},
+		{"Hebrew", "ha-ma-r", "r", []string{"ha-", "ma-"}},
+		{"Latin", "mu-ntu", "mu-ntu", nil},
+	}
+	for _, testCase := range testCases {
+		stem, stripped := affixes[testCase.language].Strip(testCase.form)
+		assert.Equal(t, testCase.stem, stem, "form: %v", testCase.form)
+		assert.Equal(t, testCase.affixes, stripped, "form: %v", testCase.form)
+	}
+
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	var parsed = decoder.parseForm("mu-ntu ~ ki-tu", affixes["Swahili"])
+	assert.Equal(t, []string{"ntu", "tu"}, parsed.clean)
+	assert.Equal(t, []string{"mu-", "ki-"}, parsed.affixes)
+	assert.Equal(t, []string{"mu"}, decoder.parseForm("mu-ntu", nil).clean)
+
+	assert.NoError(t, ioutil.WriteFile(affixesPath, []byte("LANGUAGE\tAFFIX\nSwahili\tmu\n"), 0666))
+	_, err = LoadAffixes(affixesPath)
+	assert.Error(t, err)
+
+	// Patterns must be anchored, or they would cut the middle of forms.
+	assert.NoError(t, ioutil.WriteFile(affixesPath, []byte("LANGUAGE\tPATTERN\nSwahili\t(mu|ki)-\n"), 0666))
+	_, err = LoadAffixes(affixesPath)
+	assert.Error(t, err)
+	_, err = newAffixRule("", "^mu|ni$")
+	assert.Error(t, err)
+}
+
+func TestSoundClassesDecoder_Normalization(t *testing.T) {
+	var (
+		composed   = "\u0439at"
+		decomposed = "\u0438\u0306at"
+	)
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	_, decoded := decoder.decodeForm(composed)
+	assert.Equal(t, []string{"YT"}, decoded)
+	_, decoded = decoder.decodeForm(decomposed)
+	assert.Equal(t, []string{"HT"}, decoded)
+
+	for _, form := range []string{"nfc", "nfd"} {
+		decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+		assert.NoError(t, err)
+		assert.NoError(t, decoder.SetNormalization(form, false))
+		for _, source := range []string{composed, decomposed} {
+			_, decoded := decoder.decodeForm(source)
+			assert.Equal(t, []string{"YT"}, decoded, "%s: %q", form, source)
+		}
+	}
+
+	_, decoded = decoder.decodeForm("\u0160AT")
+	assert.Equal(t, []string{"TH"}, decoded)
+	assert.NoError(t, decoder.SetNormalization("none", true))
+	_, decoded = decoder.decodeForm("\u0160AT")
+	assert.Equal(t, []string{"ST"}, decoded)
+
+	assert.Error(t, decoder.SetNormalization("nfkc", false))
+
+	// Profiles apply to normalized forms whatever the normalization of their graphemes.
+	decoder, err = NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	assert.NoError(t, decoder.SetNormalization("nfc", false))
+	decoder.Profiles = map[string]*OrthographyProfile{
+		"Russian": {graphemeToIPA: map[string]string{"\u0438\u0306": "j"}, maxGraphemeLen: 2},
+	}
+	for _, source := range []string{composed, decomposed} {
+		assert.Equal(t, "jat", decoder.normalizedProfiles()["Russian"].Apply(decoder.normalize(source)), "%q", source)
+	}
+
+	// Sounds of different classes must not become the same.
+	classes := []SoundClass{
+		{ID: "H", Name: "Vowels", Members: "He\u00e9"},
+		{ID: "Y", Name: "Glides", Members: "Y", Segments: []string{"e\u0301"}},
+	}
+	decoder, err = NewSoundClassesDecoderFromClasses(classes, DefaultRootRules())
+	assert.NoError(t, err)
+	assert.Error(t, decoder.SetNormalization("nfc", false))
+	assert.Error(t, decoder.SetNormalization("nfd", false))
+
+	// Sounds written in upper case only are folded too, other upper case sounds are shadowed.
+	decoder, err = NewSoundClassesDecoderFromPreset("dolgopolsky")
+	assert.NoError(t, err)
+	assert.NoError(t, decoder.SetNormalization("none", true))
+	for form, expected := range map[string]string{"\u023aTA": "HT", "QA": "KH", "qa": "KH"} {
+		_, decoded := decoder.decodeForm(form)
+		assert.Equal(t, []string{expected}, decoded, "form: %v", form)
+	}
+}
+
+func TestSoundClassesDecoder_Concurrent(t *testing.T) {
+	fileDecoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	scaDecoder, err := NewSoundClassesDecoderFromPreset("sca")
+	assert.NoError(t, err)
+
+	var (
+		decoders = []*SoundClassesDecoder{fileDecoder, scaDecoder, fileDecoder, scaDecoder}
+		results  = make([][]*Wordlist, len(decoders))
+		wg       sync.WaitGroup
+	)
+	for idx, decoder := range decoders {
+		wg.Add(1)
+		go func(idx int, decoder *SoundClassesDecoder) {
+			defer wg.Done()
+			results[idx], _ = decoder.Decode("../data/wordlists.xlsx", nil)
+		}(idx, decoder)
+	}
+	wg.Wait()
+
+	assert.Equal(t, results[0], results[2])
+	assert.Equal(t, results[1], results[3])
+
+	// Each decoder pads short roots with its own vowel class.
+	_, fileDecoded := fileDecoder.decodeForm("*=mV")
+	_, scaDecoded := scaDecoder.decodeForm("*=mV")
+	assert.Equal(t, []string{"MH"}, fileDecoded)
+	assert.Equal(t, []string{"MV"}, scaDecoded)
+	assert.Equal(t, 2*36, fileDecoder.UnknownSounds()[0].Count)
+}
+
+func TestSoundClassesDecoder_ConceptMap(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "concepts")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	var mapPath = filepath.Join(tmpDir, "concepts.tsv")
+	assert.NoError(t, ioutil.WriteFile(mapPath, []byte(
+		"ID\tGLOSS\tCONCEPTICON_ID\tCONCEPTICON_GLOSS\n"+
+			"# water and who are merged to test duplicates\n"+
+			"94\twater\t10\tWATER\n"+
+			"98\t\t10\tWATER\n"+
+			"\tI\t5\tI\n"), 0666))
+
+	concepts, err := NewConceptMap(mapPath)
+	assert.NoError(t, err)
+	concept, ok := concepts.Lookup(1, " i ")
+	assert.True(t, ok)
+	assert.Equal(t, &Concept{ID: 5, Gloss: "I"}, concept)
+	_, ok = concepts.Lookup(2, "ashes")
+	assert.False(t, ok)
+
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	decoder.Concepts = concepts
+
+	wordlists, err := decoder.Decode("../data/wordlists.xlsx", SelectLanguages("Proto-Indo-European"))
+	assert.NoError(t, err)
+	assert.Len(t, wordlists[0].List, 2)
+	assert.Equal(t, 5, wordlists[0].List[0].SwadeshID)
+	assert.Equal(t, "I", wordlists[0].List[0].SwadeshWord)
+	assert.Equal(t, 10, wordlists[0].List[1].SwadeshID)
+	assert.Equal(t, []string{"wed", "kʷi"}, wordlists[0].List[1].CleanForms)
+
+	_, err = NewConceptMap(mapPath + ".missing")
+	assert.Error(t, err)
+}
+
+func TestExclusionList(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "exclusions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	var listPath = filepath.Join(tmpDir, "exclusions.tsv")
+	assert.NoError(t, ioutil.WriteFile(listPath, []byte(
+		"LANGUAGE\tCONCEPT\tFORM\tREASON\n"+
+			"\t1\t\tnursery\n"+
+			"Latin\tfire\t\t\n"+
+			"\t\tma(m?ma)?\tnursery\n"), 0666))
+	exclusions, err := NewExclusionList(listPath)
+	assert.NoError(t, err)
+	assert.Len(t, exclusions.Exclusions, 3)
+
+	var (
+		latin = &Wordlist{Group: "Latin", List: []*Word{
+			{Group: "Latin", SwadeshID: 1, SwadeshWord: "mother", CleanForms: []string{"mater"}, DecodedForms: []string{"MT"}},
+			{Group: "Latin", SwadeshID: 2, SwadeshWord: "fire", CleanForms: []string{"ignis"}, DecodedForms: []string{"HK"}},
+			{Group: "Latin", SwadeshID: 3, SwadeshWord: "breast", CleanForms: []string{"mamma", "uber"},
+				DecodedForms: []string{"MM", "HP"}, Cognates: []int{1, 2}},
+			{Group: "Latin", SwadeshID: 4, SwadeshWord: "mouth", Forms: []string{"os ~ mama", "bucca ~ <mamma"},
+				CleanForms: []string{"os", "mama", "bucca"}, DecodedForms: []string{"HS", "MM", "PK"},
+				Sources: []int{0, 0, 1}, Loans: []string{"mamma"}},
+			{Group: "Latin", SwadeshID: 5, SwadeshWord: "mouse", Forms: []string{"mama", "mus"},
+				CleanForms: []string{"mama", "mus"}, DecodedForms: []string{"MM", "MS"}, Sources: []int{0, 1}},
+		}}
+		greek = &Wordlist{Group: "Greek", List: []*Word{
+			{Group: "Greek", SwadeshID: 1, SwadeshWord: "mother", CleanForms: []string{"meter"}, DecodedForms: []string{"MT"}},
+			{Group: "Greek", SwadeshID: 2, SwadeshWord: "fire", CleanForms: []string{"pur"}, DecodedForms: []string{"PR"}},
+			{Group: "Greek", SwadeshID: 3, SwadeshWord: "breast", CleanForms: []string{"ma", "mastos"},
+				DecodedForms: []string{"MH", "MS"}},
+		}}
+	)
+	filtered1, filtered2, excluded := exclusions.Filter(latin, greek)
+	assert.Equal(t, []string{
+		"1 mother: all languages (nursery)",
+		"Latin, 2 fire: ignis",
+		"Latin, 3 breast: mamma (nursery)",
+		"Latin, 4 mouth: mama (nursery)",
+		"Latin, 5 mouse: mama (nursery)",
+		"Greek, 3 breast: ma (nursery)",
+	}, excluded)
+
+	// Excluded loans and forms left without clean forms are removed too.
+	assert.Equal(t, []string{"os ~ mama", "bucca ~ <mamma"}, filtered1.List[2].Forms)
+	assert.Equal(t, []string{"os", "bucca"}, filtered1.List[2].CleanForms)
+	assert.Empty(t, filtered1.List[2].Loans)
+	assert.Equal(t, []string{"mus"}, filtered1.List[3].Forms)
+	assert.Equal(t, []int{0}, filtered1.List[3].Sources)
+	latin.List = latin.List[:3]
+	filtered1, _, _ = exclusions.Filter(latin, greek)
+
+	assert.Len(t, filtered1.List, 2)
+	assert.False(t, filtered1.List[0].Attested())
+	assert.Equal(t, []string{"uber"}, filtered1.List[1].CleanForms)
+	assert.Equal(t, []string{"HP"}, filtered1.List[1].DecodedForms)
+	assert.Equal(t, []int{2}, filtered1.List[1].Cognates)
+	assert.Equal(t, []string{"pur"}, filtered2.List[0].CleanForms)
+	assert.Equal(t, []string{"mastos"}, filtered2.List[1].CleanForms)
+	assert.Len(t, latin.List[2].CleanForms, 2)
+
+	// Exclusions that match nothing are reported.
+	assert.True(t, exclusions.Exclusions[1].matchesAny([]*Wordlist{greek, latin}))
+	assert.False(t, exclusions.Exclusions[1].matchesAny([]*Wordlist{greek}))
+	assert.Equal(t, `language "Latin", concept "fire"`, exclusions.Exclusions[1].String())
+	assert.Equal(t, 3, exclusions.Exclusions[1].line)
+
+	assert.NoError(t, ioutil.WriteFile(listPath, []byte("LANGUAGE\tCONCEPT\nLatin\t\n"), 0666))
+	_, err = NewExclusionList(listPath)
+	assert.Error(t, err)
+}
+
+func TestLanguageSelector(t *testing.T) {
+	var selector *LanguageSelector
+	assert.True(t, selector.Selected("Proto-Uralic"))
+
+	selector = SelectLanguages()
+	assert.True(t, selector.Selected("Proto-Uralic"))
+
+	selector = SelectLanguages("Proto-Uralic")
+	selector.Pattern = regexp.MustCompile("^Proto-Indo")
+	assert.True(t, selector.Selected("Proto-Uralic"))
+	assert.True(t, selector.Selected("Proto-Indo-European"))
+	assert.False(t, selector.Selected("Proto-Turkic"))
+
+	selector = &LanguageSelector{Exclude: map[string]bool{"Proto-Uralic": true}}
+	assert.False(t, selector.Selected("Proto-Uralic"))
+	assert.True(t, selector.Selected("Proto-Turkic"))
+
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	wordlists, err := decoder.Decode("../data/wordlists.xlsx", selector)
+	assert.NoError(t, err)
+	assert.Len(t, wordlists, 1)
+	assert.Equal(t, "Proto-Indo-European", wordlists[0].Group)
+}
+
+func TestSchema(t *testing.T) {
+	var header = []string{"Number", "Word", "Latin", "LatinNUM", "Greek", "Note", "Greek cognates"}
+
+	var sheet, err = xlsx.NewFile().AddSheet("Wordlists")
+	assert.NoError(t, err)
+	for _, cells := range [][]string{
+		{"Number", "Word", "Latin", "Greek", "Greek cognates", "Note", "Gothic", "Old Irish"},
+		{"1", "all", "omnis", "pas", "1", "", "alls", "uile"},
+		{"2", "ashes", "cinis", "tephra", "", "poetic", "azgo", "luaithred"},
+		{"3", "bark", "cortex", "phloios", "-1", "", "", "rúsc"},
+	} {
+		var row = sheet.AddRow()
+		for _, value := range cells {
+			row.AddCell().SetString(value)
+		}
+	}
+	var guessHeader []string
+	for _, cell := range sheet.Rows[0].Cells {
+		guessHeader = append(guessHeader, cell.String())
+	}
+	// Heuristics take notes for languages, but never the forms of the next language for cognate indices.
+	assert.Equal(t, []*languageColumn{
+		{name: "Latin", col: 2, cognateCol: -1},
+		{name: "Greek", col: 3, cognateCol: 4},
+		{name: "Note", col: 5, cognateCol: -1},
+		{name: "Gothic", col: 6, cognateCol: -1},
+		{name: "Old Irish", col: 7, cognateCol: -1},
+	}, guessLayout(guessHeader, sheet.Rows[1:]).languages)
+
+	schema, err := parseSchema([][]string{
+		{"Column", "Role", "Language"},
+		{"#1", "ID"},
+		{"Word", "concept"},
+		{"Latin", "language"},
+		{"LatinNUM", "cognate"},
+		{"Greek", "language"},
+		{"Note", "note"},
+		{"Greek cognates", "cognate", "Greek"},
+	})
+	assert.NoError(t, err)
+	layout, err := schema.layout(header)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, layout.idCol)
+	assert.Equal(t, 1, layout.conceptCol)
+	assert.Equal(t, []*languageColumn{
+		{name: "Latin", col: 2, cognateCol: 3},
+		{name: "Greek", col: 4, cognateCol: 6},
+	}, layout.languages)
+
+	_, err = parseSchema([][]string{{"Latin", "lang"}})
+	assert.Error(t, err)
+	schema, _ = parseSchema([][]string{{"Gothic", "language"}})
+	_, err = schema.layout(header)
+	assert.Error(t, err)
+	schema, _ = parseSchema([][]string{{"Word", "concept"}})
+	_, err = schema.layout(header)
+	assert.Error(t, err)
+
+	tmpDir, err := ioutil.TempDir("", "schema")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	wordlists, err := ioutil.ReadFile("../data/wordlists.xlsx")
+	assert.NoError(t, err)
+	var listsPath = filepath.Join(tmpDir, "wordlists.xlsx")
+	assert.NoError(t, ioutil.WriteFile(listsPath, wordlists, 0666))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "wordlists.schema.tsv"),
+		[]byte("Column\tRole\n# Proto-Indo-European is left out\nProto-Uralic\tlanguage\n"), 0666))
+
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	decoded, err := decoder.Decode(listsPath, nil)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 1)
+	assert.Equal(t, "Proto-Uralic", decoded[0].Group)
+	assert.Len(t, decoded[0].List, 50)
+}
+
+func TestSoundClassesDecoder_Validate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "validate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	var (
+		listsPath = filepath.Join(tmpDir, "wordlists.xlsx")
+		file      = xlsx.NewFile()
+	)
+	sheet, err := file.AddSheet("Wordlists")
+	assert.NoError(t, err)
+	for _, cells := range [][]string{
+		{"Number", "Word", "Latin", "Greek"},
+		{"1", "all", "omnis", ""},
+		{"2", "ashes", "cinis ~", "tephra"},
+		{"2", "", "(favilla", ""},
+		{"3", "bark", "cortex", "phloios="},
+		{"2", "ashes", "", "spodos"},
+		{"x", "belly", "venter", ""},
+		{"5", "big", "magnus"},
+	} {
+		var row = sheet.AddRow()
+		for _, value := range cells {
+			row.AddCell().SetString(value)
+		}
+	}
+	assert.NoError(t, file.Save(listsPath))
+
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	_, err = decoder.Decode("../data/wordlists.xlsx", nil)
+	assert.NoError(t, err)
+	var unknowns = decoder.UnknownSounds()
+	assert.NotEmpty(t, unknowns)
+
+	report, err := decoder.Validate(listsPath, nil, 0.5)
+	assert.NoError(t, err)
+	// Validation keeps the report of earlier Decode calls.
+	assert.Equal(t, unknowns, decoder.UnknownSounds())
+
+	var messages []string
+	for _, issue := range report.Issues {
+		messages = append(messages, issue.String())
+	}
+	assert.Equal(t, []string{
+		`ERROR: row 3, Latin: empty variant around '~' in "cinis ~"`,
+		`ERROR: row 4, Latin: unbalanced brackets in "(favilla"`,
+		`WARNING: row 5, Greek: "phloios=" decodes to an empty root`,
+		`ERROR: row 6: concept ID 2 is repeated (first seen in row 3)`,
+		`ERROR: row 7: concept ID "x" is not a number`,
+		`WARNING: row 8: row has 3 cell(s), header has 4`,
+		`WARNING: Greek: coverage is 25.0% (1 / 4 concept(s))`,
+	}, messages)
+	assert.Equal(t, 4, report.NumErrors())
+}
+
+func TestEvaluateCognates(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cognates")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	var (
+		listsPath = filepath.Join(tmpDir, "wordlists.xlsx")
+		file      = xlsx.NewFile()
+	)
+	sheet, err := file.AddSheet("Wordlists")
+	assert.NoError(t, err)
+	for _, cells := range [][]string{
+		{"Number", "Word", "Latin", "LatinNUM", "Greek", "GreekNUM"},
+		{"1", "name", "nomen", "1", "onoma", "1"},
+		{"2", "tooth", "dens", "1", "odous", "1"},
+		{"3", "two", "duo", "1", "duo", "1"},
+		{"4", "water", "aqua", "1", "hudor", "2"},
+		{"5", "fire", "ignis", "1", "pur", "2"},
+		{"6", "nose", "nasus", "1", "rhis", "-1"},
+		{"7", "hand", "manus", "1", "mano", ""},
+		{"8", "head", "kaput", "1", "kopis", "2"},
+	} {
+		var row = sheet.AddRow()
+		for _, value := range cells {
+			row.AddCell().SetString(value)
+		}
+	}
+	assert.NoError(t, file.Save(listsPath))
+
+	decoder, err := NewSoundClassesDecoder("../data/sounds.xlsx")
+	assert.NoError(t, err)
+	wordlists, err := decoder.Decode(listsPath, nil)
+	assert.NoError(t, err)
+	assert.Len(t, wordlists, 2)
+	assert.Equal(t, []int{1}, wordlists[0].List[0].Cognates)
+	assert.Empty(t, wordlists[1].List[5].Cognates)
+
+	evaluation, err := EvaluateCognates(wordlists[0], wordlists[1], DefaultMatchRule())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3 two: duo - duo"}, evaluation.TruePositives)
+	assert.Equal(t, []string{"8 head: kaput - kopis"}, evaluation.FalsePositives)
+	assert.Equal(t, []string{"1 name: nomen - onoma", "2 tooth: dens - odous"}, evaluation.FalseNegatives)
+	assert.Equal(t, 2, evaluation.TrueNegatives)
+	assert.InDelta(t, 0.5, evaluation.Precision(), 1e-9)
+	assert.InDelta(t, 1./3, evaluation.Recall(), 1e-9)
+	assert.InDelta(t, 0.4, evaluation.F1(), 1e-9)
+
+	var rule = DefaultMatchRule()
+	rule.Similarity = NewSimilarityMatrix([]ClassSimilarity{{"T", "H", 0.5}, {"N", "T", 0.5}})
+	assert.Equal(t, 0.25, rule.Score("TNS", "HTS"))
+	evaluation, err = EvaluateCognates(wordlists[0], wordlists[1], rule)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3 two: duo - duo"}, evaluation.TruePositives)
+	assert.Equal(t, []string{"8 head: kaput - kopis"}, evaluation.FalsePositives)
+	assert.Equal(t, []string{"1 name: nomen - onoma", "2 tooth: dens - odous"}, evaluation.FalseNegatives)
+}
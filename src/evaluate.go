@@ -0,0 +1,129 @@
+package src
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+)
+
+// CognateEvaluation compares matches of a rule with expert cognate judgements:
+// two words are cognate if they share a positive cognate index. Concepts lacking
+// judgements in either list are not evaluated. Like N, only full matches are
+// predicted cognates, partial scores of graded rules are not.
+type CognateEvaluation struct {
+	TruePositives  []string
+	FalsePositives []string
+	FalseNegatives []string
+	TrueNegatives  int
+}
+
+func EvaluateCognates(list1, list2 *Wordlist, rule *MatchRule) (*CognateEvaluation, error) {
+	list1, list2, _, _ = Align(list1, list2)
+
+	var (
+		out       = &CognateEvaluation{}
+		evaluated int
+	)
+	for idx := range list1.List {
+		var word1, word2 = list1.List[idx], list2.List[idx]
+		if !word1.hasCognates() || !word2.hasCognates() {
+			continue
+		}
+		evaluated++
+
+		score, match := word1.Compare(word2, rule)
+		var cognate = word1.sharesCognate(word2)
+		switch {
+		case score == 1 && cognate:
+			out.TruePositives = append(out.TruePositives, match)
+		case score == 1:
+			out.FalsePositives = append(out.FalsePositives, match)
+		case cognate:
+			out.FalseNegatives = append(out.FalseNegatives, fmt.Sprintf("%d %s: %s - %s", word1.SwadeshID,
+				word1.SwadeshWord, word1.cognateForm(word2), word2.cognateForm(word1)))
+		default:
+			out.TrueNegatives++
+		}
+	}
+
+	if evaluated == 0 {
+		return nil, errors.Errorf("%s and %s have no concepts with cognate indices in common", list1.Group, list2.Group)
+	}
+
+	return out, nil
+}
+
+func (e *CognateEvaluation) Precision() float64 {
+	return ratio(len(e.TruePositives), len(e.TruePositives)+len(e.FalsePositives))
+}
+
+func (e *CognateEvaluation) Recall() float64 {
+	return ratio(len(e.TruePositives), len(e.TruePositives)+len(e.FalseNegatives))
+}
+
+func (e *CognateEvaluation) F1() float64 {
+	var precision, recall = e.Precision(), e.Recall()
+	if precision+recall == 0 {
+		return 0
+	}
+
+	return 2 * precision * recall / (precision + recall)
+}
+
+func (e *CognateEvaluation) Print() {
+	var msg string
+	for idx, match := range e.FalsePositives {
+		msg += fmt.Sprintf("False positive %d: %s\n", idx, match)
+	}
+	for idx, match := range e.FalseNegatives {
+		msg += fmt.Sprintf("False negative %d: %s\n", idx, match)
+	}
+	log.Printf("%s", msg)
+	log.Printf("TP = %d, FP = %d, FN = %d, TN = %d\n", len(e.TruePositives), len(e.FalsePositives),
+		len(e.FalseNegatives), e.TrueNegatives)
+	log.Printf("Precision = %f, Recall = %f, F1 = %f\n", e.Precision(), e.Recall(), e.F1())
+}
+
+func ratio(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+
+	return float64(numerator) / float64(denominator)
+}
+
+func (w *Word) hasCognates() bool {
+	for _, index := range w.Cognates {
+		if index > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *Word) sharesCognate(other *Word) bool {
+	for _, index1 := range w.Cognates {
+		for _, index2 := range other.Cognates {
+			if index1 > 0 && index1 == index2 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// cognateForm returns the first clean form cognate with the other word.
+func (w *Word) cognateForm(other *Word) string {
+	for idx, index := range w.Cognates {
+		for _, otherIndex := range other.Cognates {
+			if index > 0 && index == otherIndex && idx < len(w.CleanForms) {
+				return w.CleanForms[idx]
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,268 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	exclusionLanguageCol = "language"
+	exclusionConceptCol  = "concept"
+	exclusionFormCol     = "form"
+	exclusionReasonCol   = "reason"
+)
+
+// Exclusion removes items from wordlists before comparison. Empty fields match
+// anything: a concept alone excludes the concept from every list, a language and a
+// concept exclude all forms of the concept in that language, and a form pattern
+// excludes the clean forms it matches entirely.
+type Exclusion struct {
+	Language string
+	Concept  string
+	Form     *regexp.Regexp
+	Reason   string
+	// line is the line of the exclusion in its file, pattern is its unanchored form pattern.
+	line    int
+	pattern string
+}
+
+// ExclusionList is read from a TSV file with LANGUAGE, CONCEPT (ID or gloss), FORM
+// (regular expression) and REASON columns; every row needs a concept or a form.
+type ExclusionList struct {
+	Exclusions []*Exclusion
+}
+
+func NewExclusionList(listPath string) (*ExclusionList, error) {
+	var out = &ExclusionList{}
+	err := readTSV(listPath, func(header tsvHeader) error {
+		if !header.has(exclusionConceptCol) && !header.has(exclusionFormCol) {
+			return errors.New("header must contain CONCEPT or FORM column")
+		}
+		return nil
+	}, func(row *tsvRow) (err error) {
+		var exclusion = &Exclusion{
+			Language: row.cell(exclusionLanguageCol),
+			Concept:  row.cell(exclusionConceptCol),
+			Reason:   row.cell(exclusionReasonCol),
+			line:     row.line,
+			pattern:  row.cell(exclusionFormCol),
+		}
+		if pattern := exclusion.pattern; len(pattern) > 0 {
+			if exclusion.Form, err = regexp.Compile("^(?:" + pattern + ")$"); err != nil {
+				return err
+			}
+		} else if len(exclusion.Concept) == 0 {
+			return errors.New("concept or form expected")
+		}
+		out.Exclusions = append(out.Exclusions, exclusion)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Filter applies the exclusions to two wordlists, concepts excluded from both are reported once.
+func (e *ExclusionList) Filter(list1, list2 *Wordlist) (filtered1, filtered2 *Wordlist, excluded []string) {
+	filtered1, excluded1 := e.Apply(list1)
+	filtered2, excluded2 := e.Apply(list2)
+
+	var seen = map[string]bool{}
+	for _, item := range append(excluded1, excluded2...) {
+		if !seen[item] {
+			seen[item] = true
+			excluded = append(excluded, item)
+		}
+	}
+
+	return filtered1, filtered2, excluded
+}
+
+// PrintUnmatched warns about exclusions that match nothing in the wordlists, such
+// as misspelled concepts, so they do not go unnoticed.
+func (e *ExclusionList) PrintUnmatched(lists []*Wordlist) {
+	for _, exclusion := range e.Exclusions {
+		if !exclusion.matchesAny(lists) {
+			log.Printf("WARNING: exclusion on line %d matches nothing: %s", exclusion.line, exclusion)
+		}
+	}
+}
+
+func (e *Exclusion) matchesAny(lists []*Wordlist) bool {
+	for _, list := range lists {
+		for _, word := range list.List {
+			if !e.matchesWord(word) {
+				continue
+			}
+			if e.Form == nil {
+				return true
+			}
+			for _, form := range append(append([]string{}, word.CleanForms...), word.Loans...) {
+				if e.Form.MatchString(form) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (e *Exclusion) String() string {
+	var fields []string
+	for _, field := range [][2]string{{"language", e.Language}, {"concept", e.Concept}, {"form", e.pattern}} {
+		if len(field[1]) > 0 {
+			fields = append(fields, fmt.Sprintf("%s %q", field[0], field[1]))
+		}
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// Apply returns a copy of the wordlist without the excluded items and a
+// description of every item removed. Excluded loans are removed silently as they
+// are not compared anyway.
+func (e *ExclusionList) Apply(list *Wordlist) (out *Wordlist, excluded []string) {
+	out = &Wordlist{Group: list.Group}
+	for _, word := range list.List {
+		var (
+			dropWord  bool
+			dropForms = map[int]bool{}
+			dropLoans = map[int]bool{}
+		)
+		for _, exclusion := range e.Exclusions {
+			if !exclusion.matchesWord(word) {
+				continue
+			}
+
+			switch {
+			case exclusion.Form != nil:
+				for idx, cleanForm := range word.CleanForms {
+					if !dropForms[idx] && exclusion.Form.MatchString(cleanForm) {
+						dropForms[idx] = true
+						excluded = append(excluded, exclusion.describe(word, cleanForm))
+					}
+				}
+				for idx, loan := range word.Loans {
+					dropLoans[idx] = dropLoans[idx] || exclusion.Form.MatchString(loan)
+				}
+			case len(exclusion.Language) == 0:
+				dropWord = true
+				excluded = append(excluded, exclusion.describe(word, "all languages"))
+			default:
+				for idx := range word.CleanForms {
+					dropForms[idx] = true
+				}
+				for idx := range word.Loans {
+					dropLoans[idx] = true
+				}
+				excluded = append(excluded, exclusion.describe(word, strings.Join(word.CleanForms, ", ")))
+			}
+			if dropWord {
+				break
+			}
+		}
+
+		if !dropWord {
+			out.List = append(out.List, word.withoutForms(dropForms, dropLoans))
+		}
+	}
+
+	return out, excluded
+}
+
+func (e *Exclusion) matchesWord(word *Word) bool {
+	if len(e.Language) > 0 && e.Language != word.Group {
+		return false
+	}
+	if len(e.Concept) == 0 {
+		return true
+	}
+	if id, err := strconv.Atoi(e.Concept); err == nil {
+		return id == word.SwadeshID
+	}
+
+	return normalizeGloss(e.Concept) == normalizeGloss(commentRegexp.ReplaceAllString(word.SwadeshWord, ""))
+}
+
+func (e *Exclusion) describe(word *Word, what string) string {
+	var out = fmt.Sprintf("%d %s: %s", word.SwadeshID, word.SwadeshWord, what)
+	if len(e.Language) > 0 || e.Form != nil {
+		out = fmt.Sprintf("%s, %s", word.Group, out)
+	}
+	if len(e.Reason) > 0 {
+		out += fmt.Sprintf(" (%s)", e.Reason)
+	}
+
+	return out
+}
+
+// withoutForms returns a copy of the word without the clean forms and the loans
+// of the given indices. Forms all clean forms of which are dropped are dropped as well,
+// and so are all forms if no clean forms or loans are left.
+func (w *Word) withoutForms(drop, dropLoans map[int]bool) *Word {
+	var out = w.DeepCopy()
+	if len(drop) == 0 && len(dropLoans) == 0 {
+		return out
+	}
+
+	out.Loans = nil
+	for idx, loan := range w.Loans {
+		if !dropLoans[idx] {
+			out.Loans = append(out.Loans, loan)
+		}
+	}
+
+	// Forms are kept if some of their clean forms are kept or if they have none.
+	var keptSources = map[int]bool{}
+	for idx := range w.CleanForms {
+		keptSources[w.source(idx)] = keptSources[w.source(idx)] || !drop[idx]
+	}
+	var sourceToIdx = map[int]int{}
+	out.Forms = nil
+	for idx, form := range w.Forms {
+		if kept, ok := keptSources[idx]; !ok && len(out.Loans) > 0 || kept {
+			sourceToIdx[idx] = len(out.Forms)
+			out.Forms = append(out.Forms, form)
+		}
+	}
+
+	out.CleanForms, out.DecodedForms, out.Segments, out.Cognates, out.Doubtful, out.Affixes, out.Sources =
+		nil, nil, nil, nil, nil, nil, nil
+	for idx := range w.CleanForms {
+		if drop[idx] {
+			continue
+		}
+		out.CleanForms = append(out.CleanForms, w.CleanForms[idx])
+		if idx < len(w.DecodedForms) {
+			out.DecodedForms = append(out.DecodedForms, w.DecodedForms[idx])
+		}
+		if idx < len(w.Segments) {
+			out.Segments = append(out.Segments, w.Segments[idx])
+		}
+		if idx < len(w.Cognates) {
+			out.Cognates = append(out.Cognates, w.Cognates[idx])
+		}
+		if idx < len(w.Doubtful) {
+			out.Doubtful = append(out.Doubtful, w.Doubtful[idx])
+		}
+		if idx < len(w.Affixes) {
+			out.Affixes = append(out.Affixes, w.Affixes[idx])
+		}
+		// Words built without forms count every clean form as a form of its own.
+		var source, ok = sourceToIdx[w.source(idx)]
+		if !ok {
+			source = len(out.Sources)
+		}
+		out.Sources = append(out.Sources, source)
+	}
+
+	return out
+}
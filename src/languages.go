@@ -0,0 +1,52 @@
+package src
+
+import (
+	"regexp"
+)
+
+// LanguageSelector selects language columns of a wordlists file. Languages named
+// in Names or matching Pattern are selected (all languages if neither is set),
+// then languages named in Exclude are left out.
+type LanguageSelector struct {
+	Names   map[string]bool
+	Pattern *regexp.Regexp
+	Exclude map[string]bool
+}
+
+// SelectLanguages returns a selector of the given languages.
+func SelectLanguages(names ...string) *LanguageSelector {
+	var out = &LanguageSelector{Names: map[string]bool{}}
+	for _, name := range names {
+		out.Names[name] = true
+	}
+
+	return out
+}
+
+func (s *LanguageSelector) Selected(name string) bool {
+	if s == nil {
+		return true
+	}
+	if s.Exclude[name] {
+		return false
+	}
+	if len(s.Names) == 0 && s.Pattern == nil {
+		return true
+	}
+
+	return s.Names[name] || s.Pattern != nil && s.Pattern.MatchString(name)
+}
+
+// missing returns the languages named in the selector that are not found.
+func (s *LanguageSelector) missing(found map[string]bool) (out []string) {
+	if s == nil {
+		return nil
+	}
+	for name := range s.Names {
+		if !found[name] {
+			out = append(out, name)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,186 @@
+package src
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/pkg/errors"
+)
+
+// DistanceSummary holds ASJP-style distances between two wordlists and how many
+// random permutations gave distances not greater than the observed ones.
+type DistanceSummary struct {
+	// LDN is the mean normalized Levenshtein distance between forms of the same concept.
+	LDN float64
+	// LDND divides LDN by the mean distance between forms of different concepts,
+	// which corrects for chance similarity of the two phonologies.
+	LDND      float64
+	TotalLDN  int
+	TotalLDND int
+	// Concepts is the number of concepts attested in both lists.
+	Concepts int
+}
+
+// CompareDistances runs the permutation test on LDN and LDND. With segmented set
+// distances are measured over segments of clean forms, otherwise over decoded roots.
+func CompareDistances(list1, list2 *Wordlist, segmented bool, trials float64) (*DistanceSummary, error) {
+	list1, list2, err := alignForComparison(list1, list2)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		size      = len(list1.List)
+		distances = make([][]float64, size)
+	)
+	for i, word1 := range list1.List {
+		distances[i] = make([]float64, size)
+		for j, word2 := range list2.List {
+			distances[i][j] = word1.Distance(word2, segmented)
+		}
+	}
+
+	var identity = make([]int, size)
+	for i := range identity {
+		identity[i] = i
+	}
+
+	var (
+		summary                = &DistanceSummary{}
+		total, totalCount      = sumDistances(distances)
+		ldn, ldnd, numConcepts = permutedDistances(distances, identity, total, totalCount)
+	)
+	if numConcepts == 0 {
+		return nil, errors.New("no concept is attested in both wordlists")
+	}
+	summary.LDN, summary.LDND, summary.Concepts = ldn, ldnd, numConcepts
+
+	for i := 0.; i < trials; i++ {
+		trialLDN, trialLDND, _ := permutedDistances(distances, rand.Perm(size), total, totalCount)
+		if trialLDN <= summary.LDN {
+			summary.TotalLDN++
+		}
+		if trialLDND <= summary.LDND {
+			summary.TotalLDND++
+		}
+	}
+
+	return summary, nil
+}
+
+func sumDistances(distances [][]float64) (total float64, count int) {
+	for _, row := range distances {
+		for _, distance := range row {
+			if !math.IsNaN(distance) {
+				total += distance
+				count++
+			}
+		}
+	}
+
+	return total, count
+}
+
+// permutedDistances pairs concept i of the first list with concept perm[i] of the
+// second one.
+func permutedDistances(distances [][]float64, perm []int, total float64, totalCount int) (
+	ldn, ldnd float64, count int) {
+	var same float64
+	for i, j := range perm {
+		if distance := distances[i][j]; !math.IsNaN(distance) {
+			same += distance
+			count++
+		}
+	}
+	if count == 0 {
+		return math.NaN(), math.NaN(), 0
+	}
+
+	ldn = same / float64(count)
+	if totalCount == count || total == same {
+		return ldn, ldn, count
+	}
+
+	return ldn, ldn / ((total - same) / float64(totalCount-count)), count
+}
+
+// Distance returns the smallest normalized Levenshtein distance between the forms
+// of two words, or NaN if either word has no forms.
+func (w *Word) Distance(other *Word, segmented bool) float64 {
+	var forms1, forms2 = w.distanceForms(segmented), other.distanceForms(segmented)
+	var out = math.NaN()
+	for _, form1 := range forms1 {
+		for _, form2 := range forms2 {
+			if distance := LDN(form1, form2); math.IsNaN(out) || distance < out {
+				out = distance
+			}
+		}
+	}
+
+	return out
+}
+
+func (w *Word) distanceForms(segmented bool) (out [][]string) {
+	if segmented {
+		for _, segments := range w.Segments {
+			if len(segments) > 0 {
+				out = append(out, segments)
+			}
+		}
+		return out
+	}
+
+	for _, decoded := range w.DecodedForms {
+		if len(decoded) == 0 {
+			continue
+		}
+		var classes []string
+		for _, classID := range decoded {
+			classes = append(classes, string(classID))
+		}
+		out = append(out, classes)
+	}
+
+	return out
+}
+
+// LDN is the Levenshtein distance between two sequences of segments divided by
+// the length of the longer one.
+func LDN(form1, form2 []string) float64 {
+	var maxLen = len(form1)
+	if len(form2) > maxLen {
+		maxLen = len(form2)
+	}
+	if maxLen == 0 {
+		return 0
+	}
+
+	var prev, curr = make([]int, len(form2)+1), make([]int, len(form2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(form1); i++ {
+		curr[0] = i
+		for j := 1; j <= len(form2); j++ {
+			var cost = 1
+			if form1[i-1] == form2[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return float64(prev[len(form2)]) / float64(maxLen)
+}
+
+func minInt(values ...int) int {
+	var out = values[0]
+	for _, value := range values[1:] {
+		if value < out {
+			out = value
+		}
+	}
+
+	return out
+}
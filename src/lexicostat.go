@@ -0,0 +1,129 @@
+package src
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRetention is Swadesh's retention rate of the 100-word list per millennium.
+	DefaultRetention = 0.86
+	// DefaultStarostinRate is Starostin's rate of change per millennium squared.
+	DefaultStarostinRate = 0.05
+)
+
+// CognatePercentage returns the share of concepts whose words share a cognate
+// index among the concepts having cognate indices in both lists.
+func CognatePercentage(list1, list2 *Wordlist) (percentage float64, shared, total int) {
+	list1, list2, _, _ = Align(list1, list2)
+	for idx := range list1.List {
+		var word1, word2 = list1.List[idx], list2.List[idx]
+		if !word1.hasCognates() || !word2.hasCognates() {
+			continue
+		}
+		total++
+		if word1.sharesCognate(word2) {
+			shared++
+		}
+	}
+
+	return ratio(shared, total), shared, total
+}
+
+// SwadeshTime is the classic glottochronological divergence time in millennia:
+// t = ln c / (2 ln r). It is NaN without cognates.
+func SwadeshTime(percentage, retention float64) float64 {
+	if percentage <= 0 {
+		return math.NaN()
+	}
+
+	return math.Log(percentage) / (2 * math.Log(retention))
+}
+
+// StarostinTime is Starostin's divergence time in millennia: t = sqrt(ln c / -λc).
+// It is NaN without cognates.
+func StarostinTime(percentage, rate float64) float64 {
+	if percentage <= 0 {
+		return math.NaN()
+	}
+
+	return math.Sqrt(math.Log(percentage) / (-rate * percentage))
+}
+
+// UPGMA clusters languages by average distances and returns the tree in Newick
+// format. Distances are divergence times, so a node is placed at the distance
+// between its clusters and branch lengths are differences of node heights.
+// Missing distances are NaN, no tree is built if any is missing.
+func UPGMA(names []string, distances [][]float64) (string, error) {
+	type cluster struct {
+		newick string
+		height float64
+		size   int
+	}
+
+	var (
+		clusters = make([]*cluster, len(names))
+		dist     = make([][]float64, len(names))
+	)
+	for i, name := range names {
+		clusters[i] = &cluster{newick: newickName(name), size: 1}
+		dist[i] = append([]float64{}, distances[i]...)
+	}
+	if len(clusters) == 0 {
+		return ";", nil
+	}
+	for i := range dist {
+		for j := i + 1; j < len(dist); j++ {
+			if math.IsNaN(dist[i][j]) {
+				return "", errors.Errorf("distance between %s and %s is missing", names[i], names[j])
+			}
+		}
+	}
+
+	for len(clusters) > 1 {
+		var minI, minJ = 0, 1
+		for i := range clusters {
+			for j := i + 1; j < len(clusters); j++ {
+				if dist[i][j] < dist[minI][minJ] {
+					minI, minJ = i, j
+				}
+			}
+		}
+
+		var (
+			c1, c2 = clusters[minI], clusters[minJ]
+			height = dist[minI][minJ]
+			merged = &cluster{
+				newick: fmt.Sprintf("(%s:%.3f,%s:%.3f)", c1.newick, height-c1.height, c2.newick, height-c2.height),
+				height: height,
+				size:   c1.size + c2.size,
+			}
+		)
+		for k := range clusters {
+			var average = (dist[minI][k]*float64(c1.size) + dist[minJ][k]*float64(c2.size)) / float64(merged.size)
+			dist[minI][k], dist[k][minI] = average, average
+		}
+		dist[minI][minI] = 0
+		clusters[minI] = merged
+
+		clusters = append(clusters[:minJ], clusters[minJ+1:]...)
+		dist = append(dist[:minJ], dist[minJ+1:]...)
+		for k := range dist {
+			dist[k] = append(dist[k][:minJ], dist[k][minJ+1:]...)
+		}
+	}
+
+	return clusters[0].newick + ";", nil
+}
+
+// newickName quotes a name if it holds characters reserved by Newick.
+func newickName(name string) string {
+	if strings.ContainsAny(name, " ,;:()[]'") {
+		return "'" + strings.Replace(name, "'", "''", -1) + "'"
+	}
+
+	return name
+}
@@ -0,0 +1,266 @@
+package src
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+type MatchMode string
+
+const (
+	// MatchPrefix requires the first Length classes of both roots to be identical.
+	MatchPrefix MatchMode = "prefix"
+	// MatchSubsequence requires both roots to share an ordered subsequence of Length classes.
+	MatchSubsequence MatchMode = "subsequence"
+)
+
+// SynonymPolicy tells which forms of concepts with several forms are compared.
+type SynonymPolicy string
+
+const (
+	// SynonymsAny compares every pair of forms, the best pair wins.
+	SynonymsAny SynonymPolicy = "any"
+	// SynonymsFirst compares first forms only.
+	SynonymsFirst SynonymPolicy = "first"
+	// SynonymsPenalised compares every pair of forms and divides the best score by the number of pairs.
+	SynonymsPenalised SynonymPolicy = "penalised"
+	// SynonymsCap compares every pair of the first MaxForms forms.
+	SynonymsCap SynonymPolicy = "cap"
+)
+
+// MetathesisMark prefixes descriptions of pairs that only match after metathesis.
+const MetathesisMark = "[metathesis] "
+
+// ShortFormPolicy tells how roots shorter than the match length are compared.
+type ShortFormPolicy string
+
+const (
+	// ShortExact lets short roots match identical roots only.
+	ShortExact ShortFormPolicy = "exact"
+	// ShortReject never lets short roots match.
+	ShortReject ShortFormPolicy = "reject"
+	// ShortTruncate compares as many classes as the shorter root has.
+	ShortTruncate ShortFormPolicy = "truncate"
+)
+
+// MatchRule decides whether two decoded forms are considered a match.
+type MatchRule struct {
+	Mode       MatchMode
+	Length     int
+	ShortForms ShortFormPolicy
+	// Similarity enables graded scores for roots that do not match, nil for binary scores.
+	Similarity *SimilarityMatrix
+	// Metathesis is the score of roots that match after swapping their first two
+	// classes (KR vs RK), 0 disables metathesis. It needs a Length of 2 or more.
+	Metathesis float64
+	// Doubtful is the factor applied to scores of forms marked as uncertain,
+	// 0 scores them like any other form. Below 1, their matches score less than 1
+	// and are not counted as full matches (N); spt defaults to 0.5.
+	Doubtful float64
+	Synonyms SynonymPolicy
+	// MaxForms is the number of forms per concept compared under SynonymsCap.
+	MaxForms int
+}
+
+// DefaultMatchRule is the classic rule: the first two classes must be identical.
+func DefaultMatchRule() *MatchRule {
+	return &MatchRule{Mode: MatchPrefix, Length: 2, ShortForms: ShortExact, Synonyms: SynonymsAny}
+}
+
+func NewMatchRule(mode string, length int, shortForms string) (*MatchRule, error) {
+	var out = &MatchRule{
+		Mode:       MatchMode(mode),
+		Length:     length,
+		ShortForms: ShortFormPolicy(shortForms),
+		Synonyms:   SynonymsAny,
+	}
+
+	switch out.Mode {
+	case MatchPrefix, MatchSubsequence:
+	default:
+		return nil, errors.Errorf("unknown match mode %q (expected %s or %s)", mode, MatchPrefix, MatchSubsequence)
+	}
+
+	switch out.ShortForms {
+	case ShortExact, ShortReject, ShortTruncate:
+	default:
+		return nil, errors.Errorf("unknown short forms policy %q (expected %s, %s or %s)",
+			shortForms, ShortExact, ShortReject, ShortTruncate)
+	}
+
+	if length < 1 {
+		return nil, errors.Errorf("match length must be positive, got %d", length)
+	}
+
+	return out, nil
+}
+
+func (r *MatchRule) SetSynonymPolicy(policy string, maxForms int) error {
+	switch SynonymPolicy(policy) {
+	case SynonymsAny, SynonymsFirst, SynonymsPenalised:
+	case SynonymsCap:
+		if maxForms < 1 {
+			return errors.Errorf("max forms must be positive, got %d", maxForms)
+		}
+	default:
+		return errors.Errorf("unknown synonym policy %q (expected %s, %s, %s or %s)",
+			policy, SynonymsAny, SynonymsFirst, SynonymsPenalised, SynonymsCap)
+	}
+
+	r.Synonyms, r.MaxForms = SynonymPolicy(policy), maxForms
+
+	return nil
+}
+
+// Graded tells whether the rule gives scores other than 0 and 1.
+func (r *MatchRule) Graded() bool {
+	return r.Similarity != nil || r.Metathesis > 0 || r.Synonyms == SynonymsPenalised
+}
+
+// GradedFor tells whether the rule gives scores other than 0 and 1 for the wordlists,
+// which is also the case when doubtful forms get reduced scores.
+func (r *MatchRule) GradedFor(l1, l2 *Wordlist) bool {
+	return r.Graded() || (r.Doubtful > 0 && r.Doubtful < 1 && (l1.hasDoubtful() || l2.hasDoubtful()))
+}
+
+// forms returns the decoded forms of a word compared under the synonym policy. The
+// first forms are counted among the attested forms, so all variants of a form are kept.
+func (r *MatchRule) forms(word *Word) []string {
+	var maxForms int
+	switch r.Synonyms {
+	case SynonymsFirst:
+		maxForms = 1
+	case SynonymsCap:
+		maxForms = r.MaxForms
+	default:
+		return word.DecodedForms
+	}
+
+	var count int
+	for count < len(word.DecodedForms) && word.source(count) < maxForms {
+		count++
+	}
+
+	return word.DecodedForms[:count]
+}
+
+func (r *MatchRule) Match(form1, form2 string) bool {
+	if len(form1) == 0 || len(form2) == 0 {
+		return false
+	}
+
+	var length = r.Length
+	if len(form1) < length || len(form2) < length {
+		switch r.ShortForms {
+		case ShortReject:
+			return false
+		case ShortTruncate:
+			if length = len(form1); len(form2) < length {
+				length = len(form2)
+			}
+		default:
+			return form1 == form2
+		}
+	}
+
+	if r.Mode == MatchSubsequence {
+		return commonSubsequenceLen(form1, form2) >= length
+	}
+
+	return form1[:length] == form2[:length]
+}
+
+// Score returns 1 for roots that match and 0 for roots that do not. With graded
+// scoring, roots that do not match score the product of class similarities over
+// the classes Match compares, which stays below 1.
+func (r *MatchRule) Score(form1, form2 string) float64 {
+	score, _ := r.score(form1, form2)
+	return score
+}
+
+// score also tells whether the score is due to metathesis.
+func (r *MatchRule) score(form1, form2 string) (score float64, metathesis bool) {
+	if r.Match(form1, form2) {
+		return 1, false
+	}
+
+	score = r.gradedScore(form1, form2)
+	if r.Metathesis > score && r.Length > 1 && len(form1) > 1 && form1[0] != form1[1] &&
+		r.Match(form1[1:2]+form1[:1]+form1[2:], form2) {
+		return r.Metathesis, true
+	}
+
+	return score, false
+}
+
+func (r *MatchRule) gradedScore(form1, form2 string) float64 {
+	if r.Similarity == nil || len(form1) == 0 || len(form2) == 0 {
+		return 0
+	}
+
+	var length = r.Length
+	if len(form1) < length || len(form2) < length {
+		switch r.ShortForms {
+		case ShortReject:
+			return 0
+		case ShortTruncate:
+			if length = len(form1); len(form2) < length {
+				length = len(form2)
+			}
+		default:
+			if len(form1) != len(form2) {
+				return 0
+			}
+			length = len(form1)
+		}
+	}
+
+	var score = 1.
+	for i := 0; i < length; i++ {
+		score *= r.Similarity.Get(form1[i:i+1], form2[i:i+1])
+	}
+
+	return score
+}
+
+func (r *MatchRule) String() string {
+	var out = fmt.Sprintf("%s of %d class(es), short forms: %s", r.Mode, r.Length, r.ShortForms)
+	if r.Similarity != nil {
+		out += ", graded"
+	}
+	if r.Metathesis > 0 {
+		out += fmt.Sprintf(", metathesis scores %.2f", r.Metathesis)
+	}
+	if r.Doubtful > 0 && r.Doubtful < 1 {
+		out += fmt.Sprintf(", doubtful forms score %.2f", r.Doubtful)
+	}
+	switch r.Synonyms {
+	case SynonymsFirst, SynonymsPenalised:
+		out += fmt.Sprintf(", synonyms: %s", r.Synonyms)
+	case SynonymsCap:
+		out += fmt.Sprintf(", synonyms: first %d form(s)", r.MaxForms)
+	}
+
+	return out
+}
+
+// commonSubsequenceLen returns the length of the longest common subsequence of two roots.
+func commonSubsequenceLen(form1, form2 string) int {
+	var prev, curr = make([]int, len(form2)+1), make([]int, len(form2)+1)
+	for i := 1; i <= len(form1); i++ {
+		for j := 1; j <= len(form2); j++ {
+			switch {
+			case form1[i-1] == form2[j-1]:
+				curr[j] = prev[j-1] + 1
+			case prev[j] > curr[j-1]:
+				curr[j] = prev[j]
+			default:
+				curr[j] = curr[j-1]
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(form2)]
+}
@@ -0,0 +1,170 @@
+package src
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+type nexusCharacter struct {
+	label     string
+	swadeshID int
+	cognate   int
+}
+
+// WriteNexus writes the cognate indices of wordlists as a binary character
+// matrix in NEXUS format: a character per concept and cognate set, 1 if the
+// language has a form of the set. Languages without cognate indices for a
+// concept get ? for all of its characters.
+func WriteNexus(nexusPath string, wordlists []*Wordlist) error {
+	nexusFile, err := os.Create(nexusPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", nexusPath)
+	}
+	defer nexusFile.Close()
+
+	var w = bufio.NewWriter(nexusFile)
+	if err := writeNexus(w, wordlists); err != nil {
+		return errors.Wrapf(err, "failed to write %s", nexusPath)
+	}
+	if err := w.Flush(); err != nil {
+		return errors.Wrapf(err, "failed to write %s", nexusPath)
+	}
+
+	return nexusFile.Close()
+}
+
+func writeNexus(w io.Writer, wordlists []*Wordlist) error {
+	var (
+		characters []*nexusCharacter
+		words      = make([]map[int]*Word, len(wordlists))
+		conceptIDs []int
+		glosses    = map[int]string{}
+		cognates   = map[int]map[int]bool{}
+	)
+	for idx, list := range wordlists {
+		words[idx] = map[int]*Word{}
+		for _, word := range list.List {
+			if _, ok := words[idx][word.SwadeshID]; !ok {
+				words[idx][word.SwadeshID] = word
+			}
+			if _, ok := cognates[word.SwadeshID]; !ok {
+				cognates[word.SwadeshID] = map[int]bool{}
+				conceptIDs = append(conceptIDs, word.SwadeshID)
+			}
+			if len(glosses[word.SwadeshID]) == 0 {
+				glosses[word.SwadeshID] = word.SwadeshWord
+			}
+			for _, index := range word.Cognates {
+				if index > 0 {
+					cognates[word.SwadeshID][index] = true
+				}
+			}
+		}
+	}
+
+	sort.Ints(conceptIDs)
+	for _, conceptID := range conceptIDs {
+		var indices []int
+		for index := range cognates[conceptID] {
+			indices = append(indices, index)
+		}
+		sort.Ints(indices)
+
+		for _, index := range indices {
+			characters = append(characters, &nexusCharacter{
+				label:     fmt.Sprintf("%s_%d", nexusLabel(conceptID, glosses[conceptID]), index),
+				swadeshID: conceptID,
+				cognate:   index,
+			})
+		}
+	}
+	if len(characters) == 0 {
+		return errors.New("wordlists have no cognate indices")
+	}
+
+	var out strings.Builder
+	out.WriteString("#NEXUS\n\nBEGIN DATA;\n")
+	fmt.Fprintf(&out, "\tDIMENSIONS NTAX=%d NCHAR=%d;\n", len(wordlists), len(characters))
+	out.WriteString("\tFORMAT DATATYPE=STANDARD MISSING=? GAP=- SYMBOLS=\"01\";\n")
+	out.WriteString("\tCHARSTATELABELS\n")
+	for idx, character := range characters {
+		var separator = ","
+		if idx == len(characters)-1 {
+			separator = ""
+		}
+		fmt.Fprintf(&out, "\t\t%d %s%s\n", idx+1, character.label, separator)
+	}
+	out.WriteString("\t;\n\tMATRIX\n")
+
+	var names []string
+	var maxNameLen int
+	for _, list := range wordlists {
+		var name = nexusName(list.Group)
+		names = append(names, name)
+		if len(name) > maxNameLen {
+			maxNameLen = len(name)
+		}
+	}
+	for idx, name := range names {
+		fmt.Fprintf(&out, "\t%-*s  ", maxNameLen, name)
+		for _, character := range characters {
+			word, ok := words[idx][character.swadeshID]
+			switch {
+			case !ok || !word.hasCognates():
+				out.WriteByte('?')
+			case word.hasCognate(character.cognate):
+				out.WriteByte('1')
+			default:
+				out.WriteByte('0')
+			}
+		}
+		out.WriteByte('\n')
+	}
+	out.WriteString("\t;\nEND;\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func (w *Word) hasCognate(index int) bool {
+	for _, other := range w.Cognates {
+		if other == index {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nexusLabel turns a gloss into a character label, the concept ID if there is no gloss.
+func nexusLabel(conceptID int, gloss string) string {
+	var label = strings.Map(func(char rune) rune {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			return char
+		}
+		return '_'
+	}, strings.TrimSpace(commentRegexp.ReplaceAllString(gloss, "")))
+	if len(strings.Trim(label, "_")) == 0 {
+		return fmt.Sprintf("concept%d", conceptID)
+	}
+
+	return strings.Trim(label, "_")
+}
+
+// nexusName quotes a taxon name unless it consists of letters, digits and underscores.
+func nexusName(name string) string {
+	for _, char := range name {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) && char != '_' {
+			return "'" + strings.Replace(name, "'", "''", -1) + "'"
+		}
+	}
+
+	return name
+}
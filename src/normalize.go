@@ -0,0 +1,133 @@
+package src
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizationForm is the Unicode normalization form of forms and sound models.
+type NormalizationForm string
+
+const (
+	// NormalizeNone leaves characters as they are.
+	NormalizeNone NormalizationForm = "none"
+	// NormalizeNFC composes base characters and combining marks into precomposed characters.
+	NormalizeNFC NormalizationForm = "nfc"
+	// NormalizeNFD decomposes precomposed characters into base characters and combining marks.
+	NormalizeNFD NormalizationForm = "nfd"
+)
+
+// SetNormalization makes the decoder normalize forms to the given form, and lower
+// their case if foldCase is set, before decoding. Sounds of the model are
+// normalized as well, so precomposed and decomposed characters decode alike; it is
+// an error if sounds of different classes become the same. With case folding,
+// sounds written in upper case only are folded too, while those whose lower case
+// is a sound of its own decode like that sound.
+func (d *SoundClassesDecoder) SetNormalization(form string, foldCase bool) error {
+	switch NormalizationForm(form) {
+	case NormalizeNone, NormalizeNFC, NormalizeNFD:
+	default:
+		return errors.Errorf("unknown normalization form %q (expected %s, %s or %s)",
+			form, NormalizeNone, NormalizeNFC, NormalizeNFD)
+	}
+	d.normalization, d.foldCase = NormalizationForm(form), foldCase
+
+	var sounds = map[string]string{}
+	for sound, classID := range d.SoundToClassID {
+		sounds[string(sound)] = classID
+	}
+	for segment, classID := range d.SegmentToClassID {
+		sounds[segment] = classID
+	}
+	var sorted []string
+	for sound := range sounds {
+		sorted = append(sorted, sound)
+	}
+	sort.Strings(sorted)
+
+	var added = map[string]string{}
+	for _, sound := range sorted {
+		var normalized = d.normalizeUnicode(sound)
+		if normalized == sound {
+			continue
+		}
+		for _, other := range []map[string]string{sounds, added} {
+			if classID, ok := other[normalized]; ok && classID != sounds[sound] {
+				return errors.Errorf("sound %q of class %s is %q under %s normalization, which is a sound of class %s",
+					sound, sounds[sound], normalized, form, classID)
+			}
+		}
+		added[normalized] = sounds[sound]
+	}
+
+	if foldCase {
+		for _, sound := range sorted {
+			var folded = d.normalize(sound)
+			if _, ok := sounds[folded]; ok {
+				continue
+			}
+			if _, ok := added[folded]; !ok {
+				added[folded] = sounds[sound]
+			}
+		}
+	}
+
+	for sound, classID := range added {
+		d.addSound(sound, classID)
+	}
+
+	return nil
+}
+
+// addSound adds a sound of the model unless it is already there.
+func (d *SoundClassesDecoder) addSound(sound, classID string) {
+	if utf8.RuneCountInString(sound) == 1 {
+		var char, _ = utf8.DecodeRuneInString(sound)
+		if _, ok := d.SoundToClassID[char]; !ok {
+			d.SoundToClassID[char] = classID
+		}
+		return
+	}
+
+	if _, ok := d.SegmentToClassID[sound]; !ok {
+		d.SegmentToClassID[sound] = classID
+	}
+	if soundLen := utf8.RuneCountInString(sound); soundLen > d.maxSegmentLen {
+		d.maxSegmentLen = soundLen
+	}
+}
+
+// normalize prepares a form for decoding.
+func (d *SoundClassesDecoder) normalize(form string) string {
+	if d.foldCase {
+		form = strings.ToLower(form)
+	}
+
+	return d.normalizeUnicode(form)
+}
+
+// normalizedProfiles returns the orthography profiles with graphemes normalized
+// like forms, so they apply to forms in any normalization form.
+func (d *SoundClassesDecoder) normalizedProfiles() map[string]*OrthographyProfile {
+	var out = map[string]*OrthographyProfile{}
+	for name, profile := range d.Profiles {
+		out[name] = profile.normalized(d.normalize)
+	}
+
+	return out
+}
+
+func (d *SoundClassesDecoder) normalizeUnicode(text string) string {
+	switch d.normalization {
+	case NormalizeNFC:
+		return norm.NFC.String(text)
+	case NormalizeNFD:
+		return norm.NFD.String(text)
+	}
+
+	return text
+}
@@ -0,0 +1,140 @@
+package src
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// vowels is shared by all built-in models: vowel quality is never part of a root.
+const vowels = "AEIOUaeiouÀÁÂÃÄÅÆÈÉÊËÌÍÎÏÒÓÔÕÖØÙÚÛÜàáâãäåæèéêëìíîïòóôõöøùúûü" +
+	"ĀāĂăĄąĒēĔĕĖėĘęĚěĨĩĪīĬĭĮįİıŌōŎŏŐőŒœŨũŪūŬŭŮůŰűŲųƎƏƐƗƖƜƟơƠƯưƱƲǍ" +
+	"ǎǏǐǑǒǓǔǕǖǗǘǙǚǛǜǝǞǟǠǡǢǣǪǫǬǭǺǻǼǽǾǿȀȁȂȃȄȅȆȇȈȉȊȋȌȍȎȏȔȕȖȗȢȣȦȧȨȩȪȫ" +
+	"ȬȭȮȯȰȱȺɄɅɆɇɐɑɒɏɎɔɘəɚɛɜɝɞɤɨɩɪɯɵɶɷʉʊʌʏʚαεηιουωϊϋόύώάέήίЀЁЍАЕИО" +
+	"УЫЭЮЯаеиоуыэюяѐёєіїѝӐӑӒӓӔӕӖӗӘәӚӛӢӣӤӥӦӧӨөӪӫӬӭӮӯӰӱӸӹᴀᴁᴂᴇᴈᴉᴏᴐᴑᴒ" +
+	"ᴓᴔᴕᴖᴗᴜᴝᴞᴟᴥᴧᵫᵾᵿᵼᵻᶏᶐᶒᶓᶔᶕᶖᶗᶙḀḁḔḕḖḗḘḙḚḛḜḝḬḭḮḯṌṍṎṏṐṑṒṓṲṳṴṵṶṷṸṹṺṻẚ" +
+	"ẙẠạẢảẤấẦầẨẩẪẫẬậẮắẰằẲẳẴẵẶặẸẹẺẻẼẽẾếỀềỂểỄễỆệỈỉỊịỌọỎỏỐốỒồổỔỖỗỘộỚ" +
+	"ớỜờỞởỠỡỢợỤụỦủỨứỪừỬửỮữỰựὰάὲέὴήὶίὸόὺύὼώⱯꝊꝋꝌꝍꝎꝏꞶꞷꞜꞝ"
+
+var soundModels = map[string][]SoundClass{
+	// Dolgopolsky's ten classes (P, T, S, K, M, N, R, W, J and the zero class).
+	"dolgopolsky": {
+		{ID: "P", Name: "Labials", Members: "PpBbɓɸβṗFfvⱱʙпПфФбБʘ"},
+		{ID: "T", Name: "Dentals", Members: "TtDdɗṭþϑθðδʈɖȡȶǂтТдДQᴌŁƛǁ"},
+		{ID: "S", Name: "Sibilants and affricates", Members: "SʄsßʂʐZšzžʑʆʃʦʣʧʤʨʥСзЗшШжЖщЩцCcČčɕᶚɟʒǯʓсçʝǀЦчЧ",
+			Segments: []string{"ts", "dz", "tʃ", "dʒ", "tɕ", "dʑ"}},
+		{ID: "K", Name: "Velars", Members: "KkgɠḳɰqGɢʛXxɣγχꭓʁхХкКгГ"},
+		{ID: "M", Name: "Labial nasals", Members: "MmɱмМ"},
+		{ID: "N", Name: "Non-labial nasals", Members: "NnɳɲŋɴнН"},
+		{ID: "R", Name: "Liquids", Members: "RrɹɻɾɽʀрРLlłɭʎʟʫɬɮɫλлЛ"},
+		{ID: "W", Name: labialGlidesClass, Members: "WwʍʋвВ"},
+		{ID: "J", Name: glidesClass, Members: "YyJjйЙ"},
+		{ID: "H", Name: laryngealsClass, Members: "Hhħʜʔʕʡʢɦ"},
+		{ID: "H", Name: vowelsClass, Members: vowels},
+	},
+	// List's SCA consonant classes; vowel classes are collapsed into one.
+	"sca": {
+		{ID: "P", Name: "Labial plosives", Members: "pbɓ"},
+		{ID: "B", Name: "Labial fricatives", Members: "fvɸβⱱ"},
+		{ID: "M", Name: "Labial nasals", Members: "mɱ"},
+		{ID: "W", Name: labialGlidesClass, Members: "wʍʋ"},
+		{ID: "T", Name: "Dental plosives", Members: "tdʈɖɗṭ"},
+		{ID: "D", Name: "Dental fricatives", Members: "θðþ"},
+		{ID: "C", Name: "Affricates", Members: "ʦʣʧʤčǯʨʥƛ",
+			Segments: []string{"ts", "dz", "tʃ", "dʒ", "tɕ", "dʑ"}},
+		{ID: "S", Name: "Sibilants", Members: "szʃʒɕʑʂʐšžçʝ"},
+		{ID: "K", Name: "Velar plosives", Members: "kgqɢɠcɟ"},
+		{ID: "G", Name: "Velar fricatives", Members: "xɣχʁ"},
+		{ID: "N", Name: "Nasals", Members: "nŋɲɳɴ"},
+		{ID: "L", Name: "Laterals", Members: "lɫɭʎʟɬɮλł"},
+		{ID: "R", Name: "Trills", Members: "rɾɽʀʙɹɻ"},
+		{ID: "J", Name: glidesClass, Members: "jɰ"},
+		{ID: "H", Name: laryngealsClass, Members: "hɦʔħʕ"},
+		{ID: "V", Name: vowelsClass, Members: vowels},
+	},
+	// ASJP consonant symbols; h and 7 are merged since laryngeals only count at word edges.
+	"asjp": {
+		{ID: "p", Name: "Voiceless labials", Members: "pɸ"},
+		{ID: "b", Name: "Voiced labials", Members: "bβɓʙ"},
+		{ID: "f", Name: "Voiceless labiodentals", Members: "f"},
+		{ID: "v", Name: "Voiced labiodentals", Members: "vⱱʋ"},
+		{ID: "m", Name: "Labial nasals", Members: "mɱ"},
+		{ID: "w", Name: labialGlidesClass, Members: "wʍ"},
+		{ID: "8", Name: "Dental fricatives", Members: "θðþ"},
+		{ID: "t", Name: "Voiceless dentals", Members: "tʈ"},
+		{ID: "d", Name: "Voiced dentals", Members: "dɖɗ"},
+		{ID: "s", Name: "Voiceless alveolar fricatives", Members: "s"},
+		{ID: "z", Name: "Voiced alveolar fricatives", Members: "z"},
+		{ID: "c", Name: "Alveolar affricates", Members: "ʦʣ", Segments: []string{"ts", "dz"}},
+		{ID: "n", Name: "Alveolar nasals", Members: "nɳ"},
+		{ID: "r", Name: "Trills", Members: "rɾɽʀɹɻ"},
+		{ID: "l", Name: "Alveolar laterals", Members: "l"},
+		{ID: "S", Name: "Voiceless postalveolar fricatives", Members: "ʃʂɕš"},
+		{ID: "Z", Name: "Voiced postalveolar fricatives", Members: "ʒʐʑž"},
+		{ID: "C", Name: "Voiceless palato-alveolar affricates", Members: "ʧʨč", Segments: []string{"tʃ", "tɕ"}},
+		{ID: "j", Name: "Voiced palato-alveolar affricates", Members: "ʤʥǯ", Segments: []string{"dʒ", "dʑ"}},
+		{ID: "T", Name: "Palatal stops", Members: "cɟ"},
+		{ID: "5", Name: "Palatal nasals", Members: "ɲ"},
+		{ID: "y", Name: glidesClass, Members: "jy"},
+		{ID: "k", Name: "Voiceless velars", Members: "k"},
+		{ID: "g", Name: "Voiced velars", Members: "gɠɰ"},
+		{ID: "x", Name: "Velar and palatal fricatives", Members: "xɣçʝ"},
+		{ID: "N", Name: "Velar and uvular nasals", Members: "ŋɴ"},
+		{ID: "q", Name: "Voiceless uvulars", Members: "q"},
+		{ID: "G", Name: "Voiced uvulars", Members: "ɢʛ"},
+		{ID: "X", Name: "Uvular and pharyngeal fricatives", Members: "χʁħʕ"},
+		{ID: "h", Name: laryngealsClass, Members: "hɦʔ"},
+		{ID: "L", Name: "Other laterals", Members: "ʎʟɭɬɮłɫλ"},
+		{ID: "!", Name: "Clicks", Members: "ǀǁǂǃʘ"},
+		{ID: "V", Name: vowelsClass, Members: vowels},
+	},
+}
+
+// soundModelSimilarities are keyed by class IDs: closer places or manners of
+// articulation score higher.
+var soundModelSimilarities = map[string][]ClassSimilarity{
+	"dolgopolsky": {
+		{"T", "S", 0.5}, {"M", "N", 0.5}, {"W", "J", 0.5}, {"P", "W", 0.25}, {"K", "H", 0.25},
+	},
+	"sca": {
+		{"P", "B", 0.5}, {"B", "W", 0.5}, {"T", "D", 0.5}, {"T", "C", 0.5}, {"C", "S", 0.5},
+		{"D", "S", 0.5}, {"K", "G", 0.5}, {"G", "H", 0.5}, {"L", "R", 0.5}, {"M", "N", 0.5},
+		{"J", "W", 0.5},
+	},
+	"asjp": {
+		{"p", "b", 0.75}, {"f", "v", 0.75}, {"p", "f", 0.5}, {"b", "v", 0.5}, {"b", "w", 0.25},
+		{"t", "d", 0.75}, {"t", "8", 0.5}, {"d", "8", 0.5}, {"s", "z", 0.75}, {"S", "Z", 0.75},
+		{"s", "S", 0.5}, {"z", "Z", 0.5}, {"c", "s", 0.5}, {"c", "C", 0.5}, {"C", "S", 0.5},
+		{"j", "Z", 0.5}, {"C", "j", 0.75}, {"T", "k", 0.5}, {"T", "C", 0.5}, {"k", "g", 0.75},
+		{"k", "q", 0.5}, {"g", "G", 0.5}, {"q", "G", 0.75}, {"x", "X", 0.5}, {"x", "k", 0.5},
+		{"X", "q", 0.5}, {"l", "L", 0.75}, {"l", "r", 0.5}, {"m", "n", 0.5}, {"n", "N", 0.5},
+		{"n", "5", 0.5}, {"y", "w", 0.5}, {"h", "X", 0.25},
+	},
+}
+
+func SoundModelNames() []string {
+	var names []string
+	for name := range soundModels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func NewSoundClassesDecoderFromPreset(name string) (*SoundClassesDecoder, error) {
+	classes, ok := soundModels[strings.ToLower(name)]
+	if !ok {
+		return nil, errors.Errorf("unknown sound model %q (expected one of %s)",
+			name, strings.Join(SoundModelNames(), ", "))
+	}
+
+	out, err := NewSoundClassesDecoderFromClasses(classes, DefaultRootRules())
+	if err != nil {
+		return nil, err
+	}
+	out.Similarity = NewSimilarityMatrix(soundModelSimilarities[strings.ToLower(name)])
+
+	return out, nil
+}
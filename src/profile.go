@@ -0,0 +1,143 @@
+package src
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	profileGraphemeCol = "grapheme"
+	profileIPACol      = "ipa"
+	profileNull        = "NULL"
+	profileExtension   = ".tsv"
+)
+
+// OrthographyProfile is a CLDF-style orthography profile: a TSV file with a
+// Grapheme and an IPA column. Graphemes are replaced by longest match, anything
+// not listed in the profile is left as is.
+type OrthographyProfile struct {
+	graphemeToIPA  map[string]string
+	maxGraphemeLen int
+}
+
+func NewOrthographyProfile(profilePath string) (*OrthographyProfile, error) {
+	var out = &OrthographyProfile{graphemeToIPA: map[string]string{}}
+	err := readTSV(profilePath, func(header tsvHeader) error {
+		if !header.has(profileGraphemeCol) || !header.has(profileIPACol) {
+			return errors.New("header must contain Grapheme and IPA columns")
+		}
+		return nil
+	}, func(row *tsvRow) error {
+		var graphemeIdx, ipaIdx = row.header[profileGraphemeCol], row.header[profileIPACol]
+		if graphemeIdx >= len(row.cells) || ipaIdx >= len(row.cells) {
+			return errors.Errorf("less than %d cells", ipaIdx+1)
+		}
+
+		var (
+			grapheme = row.cell(profileGraphemeCol)
+			ipa      = row.cell(profileIPACol)
+		)
+		if len(grapheme) == 0 {
+			return nil
+		}
+		// Profiles separate segments with spaces, but a space truncates a form.
+		if ipa == profileNull {
+			ipa = ""
+		}
+		out.graphemeToIPA[grapheme] = strings.Join(strings.Fields(ipa), "")
+		if graphemeLen := utf8.RuneCountInString(grapheme); graphemeLen > out.maxGraphemeLen {
+			out.maxGraphemeLen = graphemeLen
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// LoadOrthographyProfiles reads every profile in a directory; a file named
+// Language.tsv applies to the language column named Language.
+func LoadOrthographyProfiles(profilesDir string) (map[string]*OrthographyProfile, error) {
+	files, err := ioutil.ReadDir(profilesDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", profilesDir)
+	}
+
+	var out = map[string]*OrthographyProfile{}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != profileExtension {
+			continue
+		}
+
+		profile, err := NewOrthographyProfile(filepath.Join(profilesDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out[strings.TrimSuffix(file.Name(), profileExtension)] = profile
+	}
+
+	return out, nil
+}
+
+// normalized returns a copy of the profile with graphemes and IPA passed through
+// normalize. Graphemes that are normalized already win over those normalized to them.
+func (p *OrthographyProfile) normalized(normalize func(string) string) *OrthographyProfile {
+	var graphemes []string
+	for grapheme := range p.graphemeToIPA {
+		graphemes = append(graphemes, grapheme)
+	}
+	sort.Strings(graphemes)
+
+	var out = &OrthographyProfile{graphemeToIPA: map[string]string{}}
+	for _, normalizedOnly := range []bool{true, false} {
+		for _, grapheme := range graphemes {
+			var normalized = normalize(grapheme)
+			if _, ok := out.graphemeToIPA[normalized]; ok || normalizedOnly && normalized != grapheme {
+				continue
+			}
+			out.graphemeToIPA[normalized] = normalize(p.graphemeToIPA[grapheme])
+			if graphemeLen := utf8.RuneCountInString(normalized); graphemeLen > out.maxGraphemeLen {
+				out.maxGraphemeLen = graphemeLen
+			}
+		}
+	}
+
+	return out
+}
+
+func (p *OrthographyProfile) Apply(form string) string {
+	var (
+		out   strings.Builder
+		runes = []rune(form)
+	)
+	for idx := 0; idx < len(runes); {
+		var maxLen = p.maxGraphemeLen
+		if maxLen > len(runes)-idx {
+			maxLen = len(runes) - idx
+		}
+
+		var matched int
+		for graphemeLen := maxLen; graphemeLen > 0; graphemeLen-- {
+			if ipa, ok := p.graphemeToIPA[string(runes[idx:idx+graphemeLen])]; ok {
+				out.WriteString(ipa)
+				matched = graphemeLen
+				break
+			}
+		}
+
+		if matched == 0 {
+			out.WriteRune(runes[idx])
+			matched = 1
+		}
+		idx += matched
+	}
+
+	return out.String()
+}
@@ -0,0 +1,223 @@
+package src
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	rulesSheetName = "Rules"
+
+	// KeepAction keeps the class of a sound, SkipAction drops the sound; any other
+	// action is the name of a class to substitute.
+	KeepAction = "keep"
+	SkipAction = "skip"
+
+	spaceMarker = "space"
+)
+
+// RootRules describe how a form is turned into a root of classes.
+type RootRules struct {
+	// Length is the root length: positional rules only apply and padding only
+	// happens while the root is shorter than that.
+	Length int
+	// Positions tells how sounds of a class (by class name) are treated at the
+	// start, in the middle and at the end of a form. Other classes are always kept.
+	Positions map[string]*PositionRule
+	// Padding is the name of the class appended to short non-empty roots.
+	Padding string
+	// ResetMarkers drop everything seen so far, CutMarkers drop everything after,
+	// VariantMarkers separate variants (the first marker present wins) and
+	// StripMarkers are removed from forms. DoubtMarkers flag a variant as
+	// uncertain and LoanMarkers flag it as borrowed.
+	ResetMarkers   string
+	CutMarkers     string
+	VariantMarkers string
+	StripMarkers   string
+	DoubtMarkers   string
+	LoanMarkers    string
+}
+
+type PositionRule struct {
+	Initial string
+	Medial  string
+	Final   string
+}
+
+// DefaultRootRules are the classic rules: laryngeals and vowels only count at the
+// edges of a form, glides are only kept initially, "=" resets the form, "-" and
+// space cut it.
+func DefaultRootRules() *RootRules {
+	return &RootRules{
+		Length: 2,
+		Positions: map[string]*PositionRule{
+			laryngealsClass:   {Initial: laryngealsClass, Medial: SkipAction, Final: laryngealsClass},
+			vowelsClass:       {Initial: laryngealsClass, Medial: SkipAction, Final: laryngealsClass},
+			glidesClass:       {Initial: KeepAction, Medial: SkipAction, Final: laryngealsClass},
+			labialGlidesClass: {Initial: KeepAction, Medial: SkipAction, Final: laryngealsClass},
+		},
+		Padding:        vowelsClass,
+		ResetMarkers:   "=",
+		CutMarkers:     "- ",
+		VariantMarkers: "~/",
+		StripMarkers:   "*",
+		DoubtMarkers:   "?",
+		LoanMarkers:    "!<",
+	}
+}
+
+// parseRootRules reads the optional "Rules" sheet of a sound model. Every row
+// starts with a setting name followed by its values; settings that are not
+// listed keep their default values:
+//
+//	Root length      | 2
+//	Padding          | Vowels and features
+//	Reset markers    | =
+//	Cut markers      | - | space
+//	Variant markers  | ~ | /
+//	Strip markers    | *
+//	Doubt markers    | ?
+//	Loan markers     | ! | <
+//	Class            | Glides | keep | skip | Laryngeals
+func parseRootRules(sheet *xlsx.Sheet) (*RootRules, error) {
+	var out = DefaultRootRules()
+	for idx, row := range sheet.Rows {
+		var cells []string
+		for _, cell := range row.Cells {
+			if value := strings.TrimSpace(cell.String()); len(value) > 0 {
+				cells = append(cells, value)
+			}
+		}
+		if len(cells) == 0 {
+			continue
+		}
+
+		var (
+			setting = strings.ToLower(cells[0])
+			values  = cells[1:]
+		)
+		if len(values) == 0 {
+			return nil, errors.Errorf("rules row %d: no value for %q", idx, cells[0])
+		}
+
+		switch setting {
+		case "root length":
+			length, err := strconv.Atoi(values[0])
+			if err != nil || length < 1 {
+				return nil, errors.Errorf("rules row %d: root length must be a positive integer", idx)
+			}
+			out.Length = length
+		case "padding":
+			out.Padding = values[0]
+		case "reset markers":
+			out.ResetMarkers = joinMarkers(values)
+		case "cut markers":
+			out.CutMarkers = joinMarkers(values)
+		case "variant markers":
+			out.VariantMarkers = joinMarkers(values)
+		case "strip markers":
+			out.StripMarkers = joinMarkers(values)
+		case "doubt markers":
+			out.DoubtMarkers = joinMarkers(values)
+		case "loan markers":
+			out.LoanMarkers = joinMarkers(values)
+		case "class":
+			if len(values) != 4 {
+				return nil, errors.Errorf("rules row %d: expected class name, initial, medial and final actions", idx)
+			}
+			out.Positions[values[0]] = &PositionRule{Initial: values[1], Medial: values[2], Final: values[3]}
+		default:
+			return nil, errors.Errorf("rules row %d: unknown setting %q", idx, cells[0])
+		}
+	}
+
+	return out, nil
+}
+
+func (r *RootRules) copy() *RootRules {
+	var out = *r
+	out.Positions = map[string]*PositionRule{}
+	for className, rule := range r.Positions {
+		var ruleCopy = *rule
+		out.Positions[className] = &ruleCopy
+	}
+
+	return &out
+}
+
+func joinMarkers(values []string) string {
+	var out string
+	for _, value := range values {
+		if strings.ToLower(value) == spaceMarker {
+			value = " "
+		}
+		out += value
+	}
+
+	return out
+}
+
+// ruleAction is a position rule action resolved against a sound model.
+type ruleAction struct {
+	keep    bool
+	classID string
+}
+
+type resolvedRule struct {
+	initial, medial, final ruleAction
+}
+
+// resolveRules maps the class names used by the rules to class IDs; rules for
+// classes missing from the model are ignored.
+func resolveRules(rules *RootRules, classNameToID map[string]string) (
+	positions map[string]*resolvedRule, padding string) {
+	var resolve = func(action string) ruleAction {
+		switch action {
+		case KeepAction:
+			return ruleAction{keep: true}
+		case SkipAction:
+			return ruleAction{}
+		}
+		classID, ok := classNameToID[action]
+		if !ok {
+			log.Printf("WARNING: class %q used by root rules not found, this might lead to incorrect behavior", action)
+		}
+		return ruleAction{classID: classID}
+	}
+
+	positions = map[string]*resolvedRule{}
+	for className, rule := range rules.Positions {
+		classID, ok := classNameToID[className]
+		if !ok {
+			log.Printf("WARNING: %s not found, this might lead to incorrect behavior", className)
+			continue
+		}
+		positions[classID] = &resolvedRule{
+			initial: resolve(rule.Initial),
+			medial:  resolve(rule.Medial),
+			final:   resolve(rule.Final),
+		}
+	}
+
+	return positions, resolve(rules.Padding).classID
+}
+
+// actions returns the actions applied to a sound. A sound that is both first and
+// last gets both edge actions unless they are the same.
+func (r *resolvedRule) actions(isFirst, isLast bool) (out []ruleAction) {
+	if isFirst {
+		out = append(out, r.initial)
+	}
+	if isLast && !(isFirst && r.final == r.initial) {
+		out = append(out, r.final)
+	}
+	if !isFirst && !isLast {
+		out = append(out, r.medial)
+	}
+
+	return out
+}
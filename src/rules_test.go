@@ -0,0 +1,121 @@
+package src
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tealeg/xlsx"
+)
+
+func TestParseRootRules(t *testing.T) {
+	sheet, err := xlsx.NewFile().AddSheet(rulesSheetName)
+	assert.NoError(t, err)
+	for _, cells := range [][]string{
+		{"Root length", "3"},
+		{"Cut markers", "-", "space", "+"},
+		{"Variant markers", "~"},
+		{"Class", glidesClass, KeepAction, KeepAction, laryngealsClass},
+	} {
+		row := sheet.AddRow()
+		for _, cell := range cells {
+			row.AddCell().SetString(cell)
+		}
+	}
+
+	rules, err := parseRootRules(sheet)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, rules.Length)
+	assert.Equal(t, "- +", rules.CutMarkers)
+	assert.Equal(t, "~", rules.VariantMarkers)
+	assert.Equal(t, "=", rules.ResetMarkers)
+	assert.Equal(t, &PositionRule{Initial: KeepAction, Medial: KeepAction, Final: laryngealsClass},
+		rules.Positions[glidesClass])
+	assert.Equal(t, DefaultRootRules().Positions[vowelsClass], rules.Positions[vowelsClass])
+
+	sheet.AddRow().AddCell().SetString("Root depth")
+	_, err = parseRootRules(sheet)
+	assert.Error(t, err)
+}
+
+func TestRootRules(t *testing.T) {
+	testCases := []struct {
+		rules    func(rules *RootRules)
+		expected map[string][]string
+	}{
+		{
+			rules: func(rules *RootRules) {},
+			expected: map[string][]string{
+				"*kuyu":       {"KH"},
+				"*ka":         {"KH"},
+				"*na ka":      {"NH"},
+				"*a=ka-ta":    {"KH"},
+				"*ka / *ta":   {"KH", "TH"},
+				"*ka ~ *ta/n": {"KH", "TN"},
+			},
+		},
+		{
+			rules: func(rules *RootRules) {
+				rules.Positions[glidesClass].Medial = KeepAction
+			},
+			expected: map[string][]string{
+				"*kuyu": {"KJ"},
+			},
+		},
+		{
+			rules: func(rules *RootRules) {
+				rules.Length = 3
+			},
+			expected: map[string][]string{
+				"*ka":    {"KHH"},
+				"*kuyu":  {"KHH"},
+				"*akata": {"HKT"},
+			},
+		},
+		{
+			rules: func(rules *RootRules) {
+				rules.CutMarkers = "-"
+				rules.ResetMarkers = ""
+				rules.VariantMarkers = "/"
+			},
+			expected: map[string][]string{
+				"*na ka":      {"NK"},
+				"*a=ka-ta":    {"HK"},
+				"*ka ~ *ta/n": {"KT", "NH"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		rules := DefaultRootRules()
+		testCase.rules(rules)
+		decoder, err := NewSoundClassesDecoderFromClasses(soundModels["dolgopolsky"], rules)
+		assert.NoError(t, err)
+
+		for forms, expected := range testCase.expected {
+			_, decoded := decoder.decodeForm(forms)
+			assert.Equal(t, expected, decoded, "forms: %v", forms)
+		}
+	}
+}
+
+func TestSoundClassesDecoder_SetRules(t *testing.T) {
+	var rules = DefaultRootRules()
+	decoder, err := NewSoundClassesDecoderFromClasses(soundModels["dolgopolsky"], rules)
+	assert.NoError(t, err)
+	rules.Positions[glidesClass].Medial = KeepAction
+	decoder.Rules().Positions[glidesClass].Medial = KeepAction
+	_, decoded := decoder.decodeForm("*kuyu")
+	assert.Equal(t, []string{"KH"}, decoded)
+
+	assert.NoError(t, decoder.SetRules(rules))
+	_, decoded = decoder.decodeForm("*kuyu")
+	assert.Equal(t, []string{"KJ"}, decoded)
+	assert.Equal(t, KeepAction, decoder.Rules().Positions[glidesClass].Medial)
+
+	// "k" is a velar of the model, so it cannot flag loans.
+	rules.LoanMarkers = "<k"
+	assert.Error(t, decoder.SetRules(rules))
+	_, err = NewSoundClassesDecoderFromClasses(soundModels["dolgopolsky"], rules)
+	assert.Error(t, err)
+	assert.Equal(t, "!<", decoder.Rules().LoanMarkers)
+}
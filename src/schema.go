@@ -0,0 +1,262 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	schemaSheetName  = "Schema"
+	schemaExtension  = ".schema.tsv"
+	schemaColumnHead = "column"
+)
+
+// ColumnRole is the role of a wordlists file column.
+type ColumnRole string
+
+const (
+	RoleID       ColumnRole = "id"
+	RoleConcept  ColumnRole = "concept"
+	RoleLanguage ColumnRole = "language"
+	// RoleCognate columns hold cognate indices of a language column, negative
+	// indices exclude forms from comparison.
+	RoleCognate  ColumnRole = "cognate"
+	RoleNote     ColumnRole = "note"
+	RoleMetadata ColumnRole = "metadata"
+)
+
+// SchemaColumn declares the role of a column given by its header or by its
+// 1-based number prefixed with "#". Language names the language column of a
+// cognate column, the nearest language column to the left by default.
+type SchemaColumn struct {
+	Column   string
+	Role     ColumnRole
+	Language string
+}
+
+// Schema declares the columns of a wordlists file; columns that are not listed
+// are ignored. It is read from a sheet named Schema of the wordlists file or from
+// a <wordlists>.schema.tsv file next to it; without a schema the columns are guessed.
+type Schema struct {
+	Columns []SchemaColumn
+}
+
+func NewSchema(schemaPath string) (*Schema, error) {
+	var rows [][]string
+	err := readTSV(schemaPath, nil, func(row *tsvRow) error {
+		rows = append(rows, row.cells)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := parseSchema(rows)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read schema from %s", schemaPath)
+	}
+
+	return out, nil
+}
+
+func parseSchemaSheet(sheet *xlsx.Sheet) (*Schema, error) {
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		var cells []string
+		for _, cell := range row.Cells {
+			cells = append(cells, cell.String())
+		}
+		rows = append(rows, cells)
+	}
+
+	return parseSchema(rows)
+}
+
+// parseSchema reads rows of column, role and optional language cells; a header
+// row starting with "Column" is skipped.
+func parseSchema(rows [][]string) (*Schema, error) {
+	var out = &Schema{}
+	for idx, row := range rows {
+		var cells = make([]string, 3)
+		for cellIdx := 0; cellIdx < len(cells) && cellIdx < len(row); cellIdx++ {
+			cells[cellIdx] = strings.TrimSpace(row[cellIdx])
+		}
+		if len(cells[0]) == 0 || idx == 0 && strings.ToLower(cells[0]) == schemaColumnHead {
+			continue
+		}
+
+		var column = SchemaColumn{Column: cells[0], Role: ColumnRole(strings.ToLower(cells[1])), Language: cells[2]}
+		switch column.Role {
+		case RoleID, RoleConcept, RoleLanguage, RoleCognate, RoleNote, RoleMetadata:
+		default:
+			return nil, errors.Errorf("row %d: unknown role %q of column %q", idx+1, cells[1], column.Column)
+		}
+		out.Columns = append(out.Columns, column)
+	}
+
+	return out, nil
+}
+
+type languageColumn struct {
+	name string
+	col  int
+	// cognateCol is -1 for languages without cognate indices.
+	cognateCol int
+}
+
+// columnLayout tells where the parts of a wordlists file are.
+type columnLayout struct {
+	idCol      int
+	conceptCol int
+	languages  []*languageColumn
+}
+
+// guessLayout finds columns by heuristics: languages start at the third column,
+// columns ending in NUM are skipped and a language column may be followed by a
+// column of cognate indices, which holds nothing but integers in the data rows.
+func guessLayout(header []string, rows []*xlsx.Row) *columnLayout {
+	var out = &columnLayout{idCol: swadeshIDCol, conceptCol: swadeshWordCol}
+
+	var maxGroupIdx = groupsStartCol
+	for groupIdx := groupsStartCol; groupIdx < len(header); groupIdx++ {
+		if len(header[groupIdx]) > 0 {
+			maxGroupIdx++
+		}
+	}
+
+	for groupIdx := groupsStartCol; groupIdx < maxGroupIdx; groupIdx++ {
+		if strings.HasSuffix(header[groupIdx], "NUM") {
+			continue
+		}
+
+		var language = &languageColumn{name: header[groupIdx], col: groupIdx, cognateCol: -1}
+		if groupIdx+1 < maxGroupIdx && isIndexColumn(rows, groupIdx+1) {
+			language.cognateCol = groupIdx + 1
+			groupIdx++
+		}
+		out.languages = append(out.languages, language)
+	}
+
+	return out
+}
+
+// isIndexColumn tells whether a column has cells and all of them are integers.
+func isIndexColumn(rows []*xlsx.Row, col int) bool {
+	var found bool
+	for _, row := range rows {
+		if len(strings.TrimSpace(cellString(row.Cells, col))) == 0 {
+			continue
+		}
+		if _, err := cellInt(row.Cells, col); err != nil {
+			return false
+		}
+		found = true
+	}
+
+	return found
+}
+
+func (s *Schema) layout(header []string) (*columnLayout, error) {
+	var (
+		out         = &columnLayout{idCol: swadeshIDCol, conceptCol: swadeshWordCol}
+		colToLang   = map[int]*languageColumn{}
+		nameToLang  = map[string]*languageColumn{}
+		cognateCols = map[int]string{}
+	)
+	for _, column := range s.Columns {
+		col, err := s.findColumn(column.Column, header)
+		if err != nil {
+			return nil, err
+		}
+
+		switch column.Role {
+		case RoleID:
+			out.idCol = col
+		case RoleConcept:
+			out.conceptCol = col
+		case RoleLanguage:
+			var language = &languageColumn{name: header[col], col: col, cognateCol: -1}
+			out.languages = append(out.languages, language)
+			colToLang[col], nameToLang[language.name] = language, language
+		case RoleCognate:
+			cognateCols[col] = column.Language
+		}
+	}
+
+	if len(out.languages) == 0 {
+		return nil, errors.New("schema declares no language columns")
+	}
+
+	for col, languageName := range cognateCols {
+		var language = nameToLang[languageName]
+		if len(languageName) == 0 {
+			for langCol := col - 1; langCol >= 0 && language == nil; langCol-- {
+				language = colToLang[langCol]
+			}
+		}
+		if language == nil {
+			return nil, errors.Errorf("no language column for cognate column %d (%q)", col+1, header[col])
+		}
+		language.cognateCol = col
+	}
+
+	return out, nil
+}
+
+// findColumn returns the index of a column given by its header or as #number.
+func (s *Schema) findColumn(column string, header []string) (int, error) {
+	if strings.HasPrefix(column, "#") {
+		number, err := strconv.Atoi(column[1:])
+		if err != nil || number < 1 || number > len(header) {
+			return 0, errors.Errorf("invalid column number %q", column)
+		}
+		return number - 1, nil
+	}
+
+	for idx, name := range header {
+		if strings.TrimSpace(name) == column {
+			return idx, nil
+		}
+	}
+
+	return 0, errors.Errorf("column %q is not in the header", column)
+}
+
+// findSchema returns the schema of a wordlists file, nil if there is none.
+func findSchema(listsPath string, file *xlsx.File) (*Schema, error) {
+	for _, sheet := range file.Sheets[1:] {
+		if sheet.Name == schemaSheetName {
+			schema, err := parseSchemaSheet(sheet)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read schema from %s", listsPath)
+			}
+			return schema, nil
+		}
+	}
+
+	var schemaPath = strings.TrimSuffix(listsPath, filepath.Ext(listsPath)) + schemaExtension
+	if _, err := os.Stat(schemaPath); err != nil {
+		return nil, nil
+	}
+
+	return NewSchema(schemaPath)
+}
+
+func cellString(row []*xlsx.Cell, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return row[idx].String()
+}
+
+func cellInt(row []*xlsx.Cell, idx int) (int, error) {
+	if idx < 0 || idx >= len(row) {
+		return 0, errors.Errorf("column %d is missing", idx)
+	}
+	return row[idx].Int()
+}
@@ -0,0 +1,68 @@
+package src
+
+import (
+	"unicode"
+)
+
+// segment is a single sound of a form: the longest sound known to the model
+// followed by any diacritics and modifier letters attached to it. Characters
+// unknown to the model become segments with an empty class ID.
+type segment struct {
+	text    string
+	classID string
+}
+
+func (d *SoundClassesDecoder) segment(form string) (out []segment) {
+	var runes = []rune(form)
+	for idx := 0; idx < len(runes); {
+		if matched, classID := d.matchSegment(runes[idx:]); matched > 0 {
+			out = append(out, segment{text: string(runes[idx : idx+matched]), classID: classID})
+			idx += matched
+			continue
+		}
+
+		var char = runes[idx]
+		if classID, ok := d.SoundToClassID[char]; ok {
+			out = append(out, segment{text: string(char), classID: classID})
+		} else if isDiacritic(char) && len(out) > 0 {
+			out[len(out)-1].text += string(char)
+		} else {
+			out = append(out, segment{text: string(char)})
+		}
+		idx++
+	}
+
+	return out
+}
+
+// knownSegments returns the sounds of a form that are in the sound model.
+func (d *SoundClassesDecoder) knownSegments(form string) (out []string) {
+	for _, segment := range d.segment(form) {
+		if len(segment.classID) > 0 {
+			out = append(out, segment.text)
+		}
+	}
+
+	return out
+}
+
+// matchSegment returns the length of the longest multi-rune segment the runes
+// start with, or zero if there is none.
+func (d *SoundClassesDecoder) matchSegment(runes []rune) (int, string) {
+	var maxLen = d.maxSegmentLen
+	if maxLen > len(runes) {
+		maxLen = len(runes)
+	}
+
+	for segmentLen := maxLen; segmentLen > 1; segmentLen-- {
+		if classID, ok := d.SegmentToClassID[string(runes[:segmentLen])]; ok {
+			return segmentLen, classID
+		}
+	}
+
+	return 0, ""
+}
+
+func isDiacritic(char rune) bool {
+	return unicode.In(char, unicode.Mn, unicode.Me, unicode.Lm)
+}
@@ -0,0 +1,84 @@
+package src
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx"
+)
+
+const similaritySheetName = "Similarity"
+
+// ClassSimilarity is a symmetric similarity score between two classes, from 0
+// (unrelated) to 1 (identical).
+type ClassSimilarity struct {
+	Class1 string
+	Class2 string
+	Score  float64
+}
+
+// SimilarityMatrix holds class-to-class similarity scores by class ID. Identical
+// classes always score 1, pairs that are not listed score 0.
+type SimilarityMatrix struct {
+	scores map[[2]string]float64
+}
+
+func NewSimilarityMatrix(similarities []ClassSimilarity) *SimilarityMatrix {
+	var out = &SimilarityMatrix{scores: map[[2]string]float64{}}
+	for _, similarity := range similarities {
+		out.scores[[2]string{similarity.Class1, similarity.Class2}] = similarity.Score
+		out.scores[[2]string{similarity.Class2, similarity.Class1}] = similarity.Score
+	}
+
+	return out
+}
+
+func (m *SimilarityMatrix) Get(classID1, classID2 string) float64 {
+	if classID1 == classID2 {
+		return 1
+	}
+
+	return m.scores[[2]string{classID1, classID2}]
+}
+
+// parseSimilarity reads the optional "Similarity" sheet of a sound model: every
+// row holds two class names and their similarity score.
+func parseSimilarity(sheet *xlsx.Sheet, classNameToID map[string]string) (*SimilarityMatrix, error) {
+	var similarities []ClassSimilarity
+	for idx, row := range sheet.Rows {
+		var cells []string
+		for _, cell := range row.Cells {
+			if value := strings.TrimSpace(cell.String()); len(value) > 0 {
+				cells = append(cells, value)
+			}
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		if len(cells) != 3 {
+			return nil, errors.Errorf("similarity row %d: expected two class names and a score", idx)
+		}
+
+		score, err := strconv.ParseFloat(cells[2], 64)
+		if err != nil || score < 0 || score >= 1 {
+			return nil, errors.Errorf("similarity row %d: score must be a number from 0 to below 1", idx)
+		}
+
+		var similarity = ClassSimilarity{Score: score}
+		for _, className := range cells[:2] {
+			classID, ok := classNameToID[className]
+			if !ok {
+				return nil, errors.Errorf("similarity row %d: unknown class %q", idx, className)
+			}
+			if len(similarity.Class1) == 0 {
+				similarity.Class1 = classID
+			} else {
+				similarity.Class2 = classID
+			}
+		}
+		similarities = append(similarities, similarity)
+	}
+
+	return NewSimilarityMatrix(similarities), nil
+}
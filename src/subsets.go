@@ -0,0 +1,129 @@
+package src
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// swadesh110 holds the glosses of the Swadesh-110 list (Swadesh-100 in alphabetical
+// order followed by ten additions) as numbered in StarLing databases, ID 1 first.
+var swadesh110 = []string{
+	"all", "ashes", "bark", "belly", "big", "bird", "bite", "black", "blood", "bone",
+	"breast", "burn", "claw", "cloud", "cold", "come", "die", "dog", "drink", "dry",
+	"ear", "earth", "eat", "egg", "eye", "fat", "feather", "fire", "fish", "fly",
+	"foot", "full", "give", "good", "green", "hair", "hand", "head", "hear", "heart",
+	"horn", "I", "kill", "knee", "know", "leaf", "lie", "liver", "long", "louse",
+	"man", "many", "meat", "moon", "mountain", "mouth", "name", "neck", "new", "night",
+	"nose", "not", "one", "person", "rain", "red", "road", "root", "round", "sand",
+	"say", "see", "seed", "sit", "skin", "sleep", "small", "smoke", "stand", "star",
+	"stone", "sun", "swim", "tail", "that", "this", "thou", "tongue", "tooth", "tree",
+	"two", "walk", "warm", "water", "we", "what", "white", "who", "woman", "yellow",
+	"far", "heavy", "near", "salt", "short", "snake", "thin", "wind", "worm", "year",
+}
+
+var builtinConceptLists = map[string][]string{
+	"swadesh100": swadesh110[:100],
+	"yakhontov35": {
+		"blood", "bone", "die", "dog", "ear", "egg", "eye", "fire", "fish", "full",
+		"give", "hand", "horn", "I", "know", "louse", "moon", "name", "new", "nose",
+		"one", "salt", "stone", "sun", "tail", "this", "thou", "tongue", "tooth", "two",
+		"water", "what", "who", "wind", "year",
+	},
+	"leipzig-jakarta": {
+		"fire", "nose", "walk", "water", "mouth", "tongue", "blood", "bone", "thou", "root",
+		"come", "breast", "rain", "I", "name", "louse", "wing", "meat", "hand", "fly",
+		"night", "ear", "neck", "far", "do", "house", "stone", "bitter", "say", "tooth",
+		"hair", "big", "one", "who", "he", "hit", "foot", "horn", "this", "fish",
+		"yesterday", "drink", "black", "navel", "stand", "bite", "back", "wind", "smoke", "what",
+		"child", "egg", "give", "new", "burn", "not", "good", "know", "knee", "sand",
+		"laugh", "hear", "soil", "leaf", "red", "liver", "hide", "skin", "suck", "carry",
+		"ant", "heavy", "take", "old", "eat", "thigh", "thick", "long", "blow", "wood",
+		"run", "fall", "eye", "ashes", "tail", "dog", "cry", "tie", "see", "sweet",
+		"rope", "shade", "bird", "salt", "small", "wide", "star", "in", "hard", "crush",
+	},
+}
+
+// ConceptSubset selects concepts of wordlists by ID or gloss.
+type ConceptSubset struct {
+	Name    string
+	ids     map[int]bool
+	glosses map[string]bool
+}
+
+// ConceptListNames returns the names of built-in concept lists.
+func ConceptListNames() []string {
+	var out []string
+	for name := range builtinConceptLists {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// NewConceptSubset returns a built-in list by name or reads a file with an ID or
+// a gloss per line. Concepts of built-in lists are selected by Swadesh-110 IDs
+// if swadeshIDs is set, and by glosses only otherwise.
+func NewConceptSubset(spec string, swadeshIDs bool) (*ConceptSubset, error) {
+	var out = &ConceptSubset{Name: spec, ids: map[int]bool{}, glosses: map[string]bool{}}
+	if glosses, ok := builtinConceptLists[spec]; ok {
+		for _, gloss := range glosses {
+			out.glosses[normalizeGloss(gloss)] = true
+		}
+		if swadeshIDs {
+			for idx, gloss := range swadesh110 {
+				if out.glosses[normalizeGloss(gloss)] {
+					out.ids[idx+1] = true
+				}
+			}
+		}
+		return out, nil
+	}
+
+	subsetFile, err := os.Open(spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%q is neither a built-in concept list (%s) nor a readable file",
+			spec, strings.Join(ConceptListNames(), ", "))
+	}
+	defer subsetFile.Close()
+
+	var scanner = bufio.NewScanner(subsetFile)
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if id, err := strconv.Atoi(line); err == nil {
+			out.ids[id] = true
+		} else {
+			out.glosses[normalizeGloss(line)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", spec)
+	}
+
+	return out, nil
+}
+
+func (s *ConceptSubset) Contains(word *Word) bool {
+	return s.ids[word.SwadeshID] ||
+		s.glosses[normalizeGloss(commentRegexp.ReplaceAllString(word.SwadeshWord, ""))]
+}
+
+// Filter returns a wordlist holding the words of the subset only.
+func (s *ConceptSubset) Filter(list *Wordlist) *Wordlist {
+	var out = &Wordlist{Group: list.Group}
+	for _, word := range list.List {
+		if s.Contains(word) {
+			out.List = append(out.List, word)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,79 @@
+package src
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tsvHeader maps lowercase column names of a TSV file to their indices.
+type tsvHeader map[string]int
+
+func (h tsvHeader) has(name string) bool {
+	_, ok := h[name]
+	return ok
+}
+
+// tsvRow is a data row of a TSV file.
+type tsvRow struct {
+	line   int
+	header tsvHeader
+	cells  []string
+}
+
+// cell returns the trimmed cell of a column, empty if the column or the cell is missing.
+func (r *tsvRow) cell(name string) string {
+	if idx, ok := r.header[name]; ok && idx < len(r.cells) {
+		return strings.TrimSpace(r.cells[idx])
+	}
+	return ""
+}
+
+// readTSV reads a TSV file whose first line is a header; blank lines and lines
+// starting with "#" are skipped. The header is checked by checkHeader and every
+// data row is passed to readRow, their errors are reported with the file and line.
+// With a nil checkHeader the file has no header and the first line is a data row.
+func readTSV(path string, checkHeader func(header tsvHeader) error, readRow func(row *tsvRow) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", path)
+	}
+	defer file.Close()
+
+	var (
+		scanner = bufio.NewScanner(file)
+		header  tsvHeader
+		lineIdx int
+	)
+	for scanner.Scan() {
+		lineIdx++
+		var line = strings.TrimRight(scanner.Text(), "\r\n")
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cells := strings.Split(line, "\t")
+		if header == nil && checkHeader != nil {
+			header = tsvHeader{}
+			for idx, cell := range cells {
+				header[strings.ToLower(strings.TrimSpace(cell))] = idx
+			}
+			if err := checkHeader(header); err != nil {
+				return errors.Wrapf(err, "%s", path)
+			}
+			continue
+		}
+
+		if err := readRow(&tsvRow{line: lineIdx, header: header, cells: cells}); err != nil {
+			return errors.Wrapf(err, "%s: line %d", path, lineIdx)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package src
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 )
 
@@ -11,12 +12,19 @@ type Wordlist struct {
 	List  []*Word
 }
 
-func (l *Wordlist) Compare(other *Wordlist, weights Weights) (cost float64, matches []string) {
+// Compare compares words at the same positions, see Align for lists that differ in concepts.
+// Pairs with partial scores are listed in matches and add to the cost, but only full
+// matches are counted.
+func (l *Wordlist) Compare(other *Wordlist, weights Weights, rule *MatchRule) (
+	cost float64, count int, matches []string) {
 	for idx := range l.List {
 		word1, word2 := l.List[idx], other.List[idx]
-		if ok, match := word1.Compare(word2); ok {
-			cost += weights.GetWeight(word1.SwadeshID)
+		if score, match := word1.Compare(word2, rule); score > 0 {
+			cost += score * weights.GetWeight(word1.SwadeshID)
 			matches = append(matches, match)
+			if score == 1 {
+				count++
+			}
 		}
 	}
 
@@ -39,9 +47,7 @@ func (l *Wordlist) Combine(other *Wordlist) *Wordlist {
 		} else {
 			w1, w2 := l1[0].DeepCopy(), l2[0].DeepCopy()
 			w1.Group = fmt.Sprintf("%s, %s", w1.Group, w2.Group)
-			w1.Forms = append(w1.Forms, w2.Forms...)
-			w1.CleanForms = append(w1.CleanForms, w2.CleanForms...)
-			w1.DecodedForms = append(w1.DecodedForms, w2.DecodedForms...)
+			w1.appendForms(w2)
 			merged = append(merged, w1)
 			l1 = l1[1:]
 			l2 = l2[1:]
@@ -74,6 +80,18 @@ type Word struct {
 	Forms        []string
 	CleanForms   []string
 	DecodedForms []string
+	// Segments holds the sounds of every clean form known to the sound model.
+	Segments [][]string
+	// Cognates holds the expert cognate index of every clean form, 0 if there is none.
+	Cognates []int
+	// Doubtful tells for every clean form whether it is marked as uncertain.
+	Doubtful []bool
+	// Affixes holds the affixes stripped from every clean form, empty if there are none.
+	Affixes []string
+	// Loans holds the clean forms marked as borrowed, they are not compared.
+	Loans []string
+	// Sources holds for every clean form the index of the form in Forms it is derived from.
+	Sources []int
 }
 
 func (w *Word) PrintTransformations() {
@@ -83,8 +101,18 @@ func (w *Word) PrintTransformations() {
 	)
 
 	for idx, cleanForm := range w.CleanForms {
-		parsed = append(parsed, fmt.Sprintf("%s\t-->\t%s (Total %d symbols)",
-			cleanForm, w.DecodedForms[idx], len(w.DecodedForms[idx])))
+		var line = fmt.Sprintf("%s\t-->\t%s (Total %d symbols)",
+			cleanForm, w.DecodedForms[idx], len(w.DecodedForms[idx]))
+		if idx < len(w.Affixes) && len(w.Affixes[idx]) > 0 {
+			line += fmt.Sprintf(" [stripped %s]", w.Affixes[idx])
+		}
+		if w.isDoubtful(idx) {
+			line += " [doubtful]"
+		}
+		parsed = append(parsed, line)
+	}
+	for _, loan := range w.Loans {
+		parsed = append(parsed, fmt.Sprintf("%s\t-->\t[loan, not compared]", loan))
 	}
 
 	formatted := fmt.Sprintf("%s (%s)\nSeen as: %s\n[Transformed]\n%s\n",
@@ -93,37 +121,129 @@ func (w *Word) PrintTransformations() {
 	log.Println(formatted)
 }
 
-func (w *Word) Compare(other *Word) (bool, string) {
-	for idx1, form1 := range w.DecodedForms {
-		for idx2, form2 := range other.DecodedForms {
+// Compare returns the best score among all pairs of forms (1 for a match) and
+// a description of the best pair.
+func (w *Word) Compare(other *Word, rule *MatchRule) (bestScore float64, match string) {
+	var (
+		forms1, forms2         = rule.forms(w), rule.forms(other)
+		idx1, idx2, metathesis = -1, -1, false
+	)
+outer:
+	for i, form1 := range forms1 {
+		for j, form2 := range forms2 {
 			if len(form1) == 0 || len(form2) == 0 {
-				return false, ""
+				break outer
 			}
 
-			var (
-				isEqual  = true
-				upperIdx = 2
-			)
-
-			if len(form1) != len(form2) && (len(form1) < upperIdx || len(form2) < upperIdx) {
-				isEqual = false
-			} else {
-				for i := 0; i < upperIdx; i++ {
-					if form1[i] != form2[i] {
-						isEqual = false
-						break
-					}
+			var score, isMetathesis = rule.score(form1, form2)
+			if rule.Doubtful > 0 {
+				if w.isDoubtful(i) {
+					score *= rule.Doubtful
 				}
+				if other.isDoubtful(j) {
+					score *= rule.Doubtful
+				}
+			}
+			if score > bestScore {
+				bestScore, idx1, idx2, metathesis = score, i, j, isMetathesis
 			}
 
-			if isEqual {
-				return true, fmt.Sprintf("%d %s: %s - %s", w.SwadeshID, w.SwadeshWord,
-					w.CleanForms[idx1], other.CleanForms[idx2])
+			if bestScore == 1 {
+				break outer
 			}
 		}
 	}
+	if idx1 < 0 {
+		return 0, ""
+	}
+
+	if rule.Synonyms == SynonymsPenalised {
+		bestScore /= float64(len(forms1) * len(forms2))
+	}
+
+	match = fmt.Sprintf("%d %s: %s - %s", w.SwadeshID, w.SwadeshWord,
+		w.CleanForms[idx1], other.CleanForms[idx2])
+	if bestScore < 1 {
+		match += fmt.Sprintf(" (%.2f)", bestScore)
+	}
+	if metathesis {
+		match = MetathesisMark + match
+	}
+
+	return bestScore, match
+}
+
+// PrintFormCounts lists concepts having more than one pair of forms to compare,
+// the ones with most pairs first.
+func (l *Wordlist) PrintFormCounts(other *Wordlist) {
+	if l, other, _, _ = Align(l, other); len(l.List) == 0 {
+		return
+	}
+	var (
+		indices        []int
+		numPairs       = make([]int, len(l.List))
+		total1, total2 int
+	)
+	for idx := range l.List {
+		var num1, num2 = len(l.List[idx].DecodedForms), len(other.List[idx].DecodedForms)
+		total1, total2 = total1+num1, total2+num2
+		if numPairs[idx] = num1 * num2; numPairs[idx] > 1 {
+			indices = append(indices, idx)
+		}
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return numPairs[indices[i]] > numPairs[indices[j]]
+	})
 
-	return false, ""
+	var msg = fmt.Sprintf("Forms per concept: %.2f (%s), %.2f (%s)\n",
+		float64(total1)/float64(len(l.List)), l.Group, float64(total2)/float64(len(other.List)), other.Group)
+	for _, idx := range indices {
+		msg += fmt.Sprintf("%d %s: %d x %d = %d pair(s) of forms\n", l.List[idx].SwadeshID, l.List[idx].SwadeshWord,
+			len(l.List[idx].DecodedForms), len(other.List[idx].DecodedForms), numPairs[idx])
+	}
+	log.Println(msg)
+}
+
+func (l *Wordlist) hasDoubtful() bool {
+	for _, word := range l.List {
+		for idx := range word.CleanForms {
+			if word.isDoubtful(idx) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (w *Word) isDoubtful(formIdx int) bool {
+	return formIdx < len(w.Doubtful) && w.Doubtful[formIdx]
+}
+
+// source returns the index of the form in Forms the clean form is derived from.
+func (w *Word) source(formIdx int) int {
+	if formIdx < len(w.Sources) {
+		return w.Sources[formIdx]
+	}
+
+	return formIdx
+}
+
+func (w *Word) appendForms(other *Word) {
+	for idx := len(w.Sources); idx < len(w.CleanForms); idx++ {
+		w.Sources = append(w.Sources, w.source(idx))
+	}
+	for idx := range other.CleanForms {
+		w.Sources = append(w.Sources, len(w.Forms)+other.source(idx))
+	}
+	w.Forms = append(w.Forms, other.Forms...)
+	w.CleanForms = append(w.CleanForms, other.CleanForms...)
+	w.DecodedForms = append(w.DecodedForms, other.DecodedForms...)
+	w.Segments = append(w.Segments, other.Segments...)
+	w.Cognates = append(w.Cognates, other.Cognates...)
+	w.Doubtful = append(w.Doubtful, other.Doubtful...)
+	w.Affixes = append(w.Affixes, other.Affixes...)
+	w.Loans = append(w.Loans, other.Loans...)
 }
 
 func (w *Word) DeepCopy() *Word {
@@ -136,6 +256,24 @@ func (w *Word) DeepCopy() *Word {
 	decodedFormsCopy := make([]string, len(w.DecodedForms))
 	copy(decodedFormsCopy, w.DecodedForms)
 
+	segmentsCopy := make([][]string, len(w.Segments))
+	copy(segmentsCopy, w.Segments)
+
+	cognatesCopy := make([]int, len(w.Cognates))
+	copy(cognatesCopy, w.Cognates)
+
+	doubtfulCopy := make([]bool, len(w.Doubtful))
+	copy(doubtfulCopy, w.Doubtful)
+
+	affixesCopy := make([]string, len(w.Affixes))
+	copy(affixesCopy, w.Affixes)
+
+	loansCopy := make([]string, len(w.Loans))
+	copy(loansCopy, w.Loans)
+
+	sourcesCopy := make([]int, len(w.Sources))
+	copy(sourcesCopy, w.Sources)
+
 	return &Word{
 		Group:        w.Group,
 		SwadeshID:    w.SwadeshID,
@@ -143,5 +281,11 @@ func (w *Word) DeepCopy() *Word {
 		Forms:        formsCopy,
 		CleanForms:   cleanFormsCopy,
 		DecodedForms: decodedFormsCopy,
+		Segments:     segmentsCopy,
+		Cognates:     cognatesCopy,
+		Doubtful:     doubtfulCopy,
+		Affixes:      affixesCopy,
+		Loans:        loansCopy,
+		Sources:      sourcesCopy,
 	}
 }
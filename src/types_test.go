@@ -1,6 +1,8 @@
 package src
 
 import (
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,8 +46,8 @@ func TestCompare(t *testing.T) {
 		}
 	)
 	var (
-		costs1, _ = l1.Compare(l2, weights)
-		costs2, _ = l2.Compare(l1, weights)
+		costs1, _, _ = l1.Compare(l2, weights, DefaultMatchRule())
+		costs2, _, _ = l2.Compare(l1, weights, DefaultMatchRule())
 	)
 	assert.Equal(t, costs1, costs2)
 }
@@ -78,3 +80,452 @@ func getTestWordlists() (l1, l2 *Wordlist) {
 
 	return
 }
+
+func TestMatchRule(t *testing.T) {
+	testCases := []struct {
+		mode       string
+		length     int
+		shortForms string
+		matches    [][2]string
+		mismatches [][2]string
+	}{
+		{
+			mode: "prefix", length: 2, shortForms: "exact",
+			matches:    [][2]string{{"KR", "KR"}, {"KRT", "KRS"}, {"KH", "KH"}},
+			mismatches: [][2]string{{"KR", "KL"}, {"K", "KR"}, {"", ""}, {"RK", "KR"}},
+		},
+		{
+			mode: "prefix", length: 1, shortForms: "exact",
+			matches:    [][2]string{{"KR", "KL"}, {"K", "KR"}},
+			mismatches: [][2]string{{"KR", "TR"}},
+		},
+		{
+			mode: "prefix", length: 3, shortForms: "exact",
+			matches:    [][2]string{{"KRT", "KRT"}, {"KR", "KR"}},
+			mismatches: [][2]string{{"KR", "KRT"}, {"KRT", "KRS"}},
+		},
+		{
+			mode: "prefix", length: 3, shortForms: "reject",
+			matches:    [][2]string{{"KRTS", "KRTP"}},
+			mismatches: [][2]string{{"KR", "KR"}, {"KR", "KRT"}},
+		},
+		{
+			mode: "prefix", length: 3, shortForms: "truncate",
+			matches:    [][2]string{{"KR", "KRT"}, {"KR", "KR"}},
+			mismatches: [][2]string{{"KR", "KLT"}},
+		},
+		{
+			mode: "subsequence", length: 2, shortForms: "exact",
+			matches:    [][2]string{{"KRT", "KT"}, {"HKR", "KPR"}},
+			mismatches: [][2]string{{"KR", "RK"}, {"KRT", "TSK"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		rule, err := NewMatchRule(testCase.mode, testCase.length, testCase.shortForms)
+		assert.NoError(t, err)
+		for _, forms := range testCase.matches {
+			assert.True(t, rule.Match(forms[0], forms[1]), "%s: %v", rule, forms)
+			assert.True(t, rule.Match(forms[1], forms[0]), "%s: %v", rule, forms)
+		}
+		for _, forms := range testCase.mismatches {
+			assert.False(t, rule.Match(forms[0], forms[1]), "%s: %v", rule, forms)
+		}
+	}
+
+	_, err := NewMatchRule("suffix", 2, "exact")
+	assert.Error(t, err)
+	_, err = NewMatchRule("prefix", 0, "exact")
+	assert.Error(t, err)
+	_, err = NewMatchRule("prefix", 2, "pad")
+	assert.Error(t, err)
+}
+
+func TestCompareGraded(t *testing.T) {
+	var (
+		rule = DefaultMatchRule()
+		l1   = &Wordlist{List: []*Word{
+			{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"KR"}, CleanForms: []string{"kar"}},
+			{SwadeshID: 2, SwadeshWord: "b", DecodedForms: []string{"KR"}, CleanForms: []string{"kur"}},
+			{SwadeshID: 3, SwadeshWord: "c", DecodedForms: []string{"MN", "KR"}, CleanForms: []string{"man", "kor"}},
+		}}
+		l2 = &Wordlist{List: []*Word{
+			{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"KL"}, CleanForms: []string{"kal"}},
+			{SwadeshID: 2, SwadeshWord: "b", DecodedForms: []string{"MN"}, CleanForms: []string{"min"}},
+			{SwadeshID: 3, SwadeshWord: "c", DecodedForms: []string{"GL", "KR"}, CleanForms: []string{"gal", "kir"}},
+		}}
+	)
+	rule.Similarity = NewSimilarityMatrix([]ClassSimilarity{{"R", "L", 0.5}, {"K", "G", 0.5}})
+
+	var similarity = NewSimilarityMatrix([]ClassSimilarity{{"", "K", 0.5}, {"Ka", "G", 0.25}, {"Kb", "G", 0.75}})
+	assert.Equal(t, 0.5, similarity.Get("K", ""))
+	assert.Equal(t, 0.25, similarity.Get("G", "Ka"))
+	assert.Equal(t, 0.75, similarity.Get("G", "Kb"))
+
+	score, match := l1.List[0].Compare(l2.List[0], rule)
+	assert.Equal(t, 0.5, score)
+	assert.Equal(t, "1 a: kar - kal (0.50)", match)
+
+	score, _ = l1.List[1].Compare(l2.List[1], rule)
+	assert.Equal(t, 0., score)
+
+	score, match = l1.List[2].Compare(l2.List[2], rule)
+	assert.Equal(t, 1., score)
+	assert.Equal(t, "3 c: kor - kir", match)
+
+	cost, count, matches := l1.Compare(l2, &DefaultWeightsStore{}, rule)
+	assert.Equal(t, 1.5, cost)
+	assert.Equal(t, 1, count)
+	assert.Len(t, matches, 2)
+
+	rule.Length = 3
+	assert.False(t, rule.Match("KR", "KRT"))
+	assert.Equal(t, 0., rule.Score("KR", "KRT"))
+	assert.Equal(t, 0.5, rule.Score("KR", "KL"))
+	_, count, _ = (&Wordlist{List: []*Word{{SwadeshID: 1, DecodedForms: []string{"KR"}}}}).Compare(
+		&Wordlist{List: []*Word{{SwadeshID: 1, DecodedForms: []string{"KRT"}}}}, &DefaultWeightsStore{}, rule)
+	assert.Equal(t, 0, count)
+	rule.ShortForms = ShortTruncate
+	assert.Equal(t, 1., rule.Score("KR", "KRT"))
+	rule.ShortForms = ShortReject
+	assert.Equal(t, 0., rule.Score("KR", "KL"))
+	rule.Length, rule.ShortForms = 2, ShortExact
+
+	rule.Similarity = nil
+	cost, count, matches = l1.Compare(l2, &DefaultWeightsStore{}, rule)
+	assert.Equal(t, 1., cost)
+	assert.Equal(t, 1, count)
+	assert.Len(t, matches, 1)
+}
+
+func TestCompareMetathesis(t *testing.T) {
+	var (
+		rule = DefaultMatchRule()
+		l1   = &Wordlist{List: []*Word{
+			{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"KR"}, CleanForms: []string{"kar"}},
+			{SwadeshID: 2, SwadeshWord: "b", DecodedForms: []string{"KRT"}, CleanForms: []string{"kurt"}},
+			{SwadeshID: 3, SwadeshWord: "c", DecodedForms: []string{"KK"}, CleanForms: []string{"kik"}},
+		}}
+		l2 = &Wordlist{List: []*Word{
+			{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"RK"}, CleanForms: []string{"rak"}},
+			{SwadeshID: 2, SwadeshWord: "b", DecodedForms: []string{"KR"}, CleanForms: []string{"kir"}},
+			{SwadeshID: 3, SwadeshWord: "c", DecodedForms: []string{"KH"}, CleanForms: []string{"ka"}},
+		}}
+	)
+	cost, count, matches := l1.Compare(l2, &DefaultWeightsStore{}, rule)
+	assert.Equal(t, 1., cost)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"2 b: kurt - kir"}, matches)
+
+	rule.Metathesis = 0.5
+	assert.Equal(t, 0.5, rule.Score("KR", "RK"))
+	assert.Equal(t, 0.5, rule.Score("KRT", "RKH"))
+	assert.Equal(t, 0., rule.Score("KK", "KH"))
+	rule.Length = 1
+	assert.Equal(t, 0., rule.Score("KR", "RT"))
+	rule.Length = 2
+
+	cost, count, matches = l1.Compare(l2, &DefaultWeightsStore{}, rule)
+	assert.Equal(t, 1.5, cost)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{MetathesisMark + "1 a: kar - rak (0.50)", "2 b: kurt - kir"}, matches)
+}
+
+func TestCompareDoubtful(t *testing.T) {
+	var (
+		rule = DefaultMatchRule()
+		l1   = &Wordlist{List: []*Word{
+			{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"KR"}, CleanForms: []string{"kar"}, Doubtful: []bool{true}},
+			{SwadeshID: 2, SwadeshWord: "b", DecodedForms: []string{"ML", "MN"}, CleanForms: []string{"mal", "man"},
+				Doubtful: []bool{true, false}},
+		}}
+		l2 = &Wordlist{List: []*Word{
+			{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"KR"}, CleanForms: []string{"kir"}},
+			{SwadeshID: 2, SwadeshWord: "b", DecodedForms: []string{"ML", "MN"}, CleanForms: []string{"mol", "mun"}},
+		}}
+	)
+	assert.False(t, rule.GradedFor(l1, l2))
+	cost, count, matches := l1.Compare(l2, &DefaultWeightsStore{}, rule)
+	assert.Equal(t, 2., cost)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"1 a: kar - kir", "2 b: mal - mol"}, matches)
+
+	rule.Doubtful = 0.5
+	assert.False(t, rule.Graded())
+	assert.True(t, rule.GradedFor(l1, l2))
+	assert.False(t, rule.GradedFor(l2, l2))
+
+	cost, count, matches = l1.Compare(l2, &DefaultWeightsStore{}, rule)
+	assert.Equal(t, 1.5, cost)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"1 a: kar - kir (0.50)", "2 b: man - mun"}, matches)
+}
+
+func TestCompareSynonyms(t *testing.T) {
+	var (
+		word1 = &Word{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"MN", "KR"}, CleanForms: []string{"man", "kar"}}
+		word2 = &Word{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"TK", "KR"}, CleanForms: []string{"tek", "kir"}}
+	)
+	for _, testCase := range []struct {
+		policy   string
+		maxForms int
+		score    float64
+		match    string
+	}{
+		{"any", 0, 1, "1 a: kar - kir"},
+		{"first", 0, 0, ""},
+		{"penalised", 0, 0.25, "1 a: kar - kir (0.25)"},
+		{"cap", 1, 0, ""},
+		{"cap", 2, 1, "1 a: kar - kir"},
+	} {
+		var rule = DefaultMatchRule()
+		assert.NoError(t, rule.SetSynonymPolicy(testCase.policy, testCase.maxForms))
+
+		score, match := word1.Compare(word2, rule)
+		assert.Equal(t, testCase.score, score, testCase.policy)
+		assert.Equal(t, testCase.match, match, testCase.policy)
+	}
+
+	// Variants of the first attested form are all among the first forms.
+	var (
+		word3 = &Word{SwadeshID: 1, SwadeshWord: "a", Forms: []string{"pol ~ kur", "mun"},
+			DecodedForms: []string{"PL", "KR", "MN"}, CleanForms: []string{"pol", "kur", "mun"}, Sources: []int{0, 0, 1}}
+		word4 = &Word{SwadeshID: 1, SwadeshWord: "a", DecodedForms: []string{"KR"}, CleanForms: []string{"kir"}}
+		rule  = DefaultMatchRule()
+	)
+	assert.NoError(t, rule.SetSynonymPolicy("first", 0))
+	score, match := word1.Compare(word3, rule)
+	assert.Equal(t, 0., score)
+	assert.Equal(t, "", match)
+	score, match = word3.Compare(word4, rule)
+	assert.Equal(t, 1., score)
+	assert.Equal(t, "1 a: kur - kir", match)
+
+	rule = DefaultMatchRule()
+	assert.Error(t, rule.SetSynonymPolicy("cap", 0))
+	assert.Error(t, rule.SetSynonymPolicy("all", 0))
+}
+
+func TestLDN(t *testing.T) {
+	assert.Equal(t, 0., LDN([]string{"k", "a"}, []string{"k", "a"}))
+	assert.Equal(t, 0.5, LDN([]string{"k", "a"}, []string{"g", "a"}))
+	assert.Equal(t, 0.75, LDN([]string{"ts", "a", "r"}, []string{"t", "s", "a", "l"}))
+	assert.Equal(t, 1., LDN([]string{"m"}, nil))
+
+	var (
+		word1 = &Word{Segments: [][]string{{"m", "a"}, {"k", "a", "l"}}, DecodedForms: []string{"MH", "KL"}}
+		word2 = &Word{Segments: [][]string{{"k", "a", "r"}}, DecodedForms: []string{"KR"}}
+	)
+	assert.InDelta(t, 1./3, word1.Distance(word2, true), 1e-9)
+	assert.Equal(t, 0.5, word1.Distance(word2, false))
+	assert.True(t, math.IsNaN(word1.Distance(&Word{}, true)))
+}
+
+func TestCompareDistances(t *testing.T) {
+	var (
+		list1 = &Wordlist{List: []*Word{
+			{SwadeshID: 1, Segments: [][]string{{"k", "a", "l"}}},
+			{SwadeshID: 2, Segments: [][]string{{"m", "o", "r"}}},
+			{SwadeshID: 3, Segments: [][]string{{"t", "e"}}},
+			{SwadeshID: 4},
+		}}
+		list2 = &Wordlist{List: []*Word{
+			{SwadeshID: 1, Segments: [][]string{{"k", "a", "r"}}},
+			{SwadeshID: 2, Segments: [][]string{{"m", "o", "r"}}},
+			{SwadeshID: 3, Segments: [][]string{{"p", "i", "s"}}},
+			{SwadeshID: 4, Segments: [][]string{{"a"}}},
+		}}
+	)
+	summary, err := CompareDistances(list1, list2, true, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Concepts)
+	assert.InDelta(t, (1./3+0+1)/3, summary.LDN, 1e-9)
+	assert.InDelta(t, summary.LDN/((9-2./3)/9), summary.LDND, 1e-9)
+	assert.True(t, summary.TotalLDND <= 100)
+
+	_, err = CompareDistances(list1, &Wordlist{}, true, 1)
+	assert.Error(t, err)
+}
+
+func TestAlign(t *testing.T) {
+	var (
+		list1 = &Wordlist{Group: "1", List: []*Word{
+			{SwadeshID: 1, SwadeshWord: "a"}, {SwadeshID: 2, SwadeshWord: "b"}, {SwadeshID: 4, SwadeshWord: "d"},
+		}}
+		list2 = &Wordlist{Group: "2", List: []*Word{
+			{SwadeshID: 4, SwadeshWord: "d"}, {SwadeshID: 3, SwadeshWord: "c"}, {SwadeshID: 1, SwadeshWord: "a"},
+		}}
+	)
+	aligned1, aligned2, only1, only2 := Align(list1, list2)
+	assert.Equal(t, "1", aligned1.Group)
+	assert.Equal(t, "2", aligned2.Group)
+	for idx, expectedID := range []int{1, 4} {
+		assert.Equal(t, expectedID, aligned1.List[idx].SwadeshID)
+		assert.Equal(t, expectedID, aligned2.List[idx].SwadeshID)
+	}
+	assert.Equal(t, []*Word{list1.List[1]}, only1)
+	assert.Equal(t, []*Word{list2.List[1]}, only2)
+
+	_, _, err := alignForComparison(list1, &Wordlist{Group: "3"})
+	assert.Error(t, err)
+
+	// Words of the same concept are merged instead of one of them being dropped.
+	var list3 = &Wordlist{Group: "3", List: []*Word{
+		{SwadeshID: 1, SwadeshWord: "a", Forms: []string{"kar"}, CleanForms: []string{"kar"}, DecodedForms: []string{"KR"}},
+		{SwadeshID: 1, SwadeshWord: "a", Forms: []string{"mal"}, CleanForms: []string{"mal"}, DecodedForms: []string{"ML"}},
+	}}
+	aligned3, aligned1, _, _ := Align(list3, list1)
+	assert.Len(t, aligned3.List, 1)
+	assert.Equal(t, list1.List[0], aligned1.List[0])
+	assert.Equal(t, []string{"kar", "mal"}, aligned3.List[0].Forms)
+	assert.Equal(t, []string{"KR", "ML"}, aligned3.List[0].DecodedForms)
+	assert.Equal(t, []int{0, 1}, aligned3.List[0].Sources)
+	assert.Equal(t, []string{"kar"}, list3.List[0].Forms)
+}
+
+func TestDropMissing(t *testing.T) {
+	var (
+		list1 = &Wordlist{Group: "1", List: []*Word{
+			{SwadeshID: 1, DecodedForms: []string{"KR"}},
+			{SwadeshID: 2, DecodedForms: []string{""}},
+			{SwadeshID: 3, DecodedForms: []string{"MN"}},
+			{SwadeshID: 5, DecodedForms: []string{"TK"}},
+		}}
+		list2 = &Wordlist{Group: "2", List: []*Word{
+			{SwadeshID: 1, DecodedForms: []string{"KL"}},
+			{SwadeshID: 2, DecodedForms: []string{"PT"}},
+			{SwadeshID: 3},
+			{SwadeshID: 4, DecodedForms: []string{"NM"}},
+		}}
+	)
+	filtered1, filtered2 := DropMissing(list1, list2)
+	assert.Equal(t, []*Word{list1.List[0], list1.List[3]}, filtered1.List)
+	assert.Equal(t, []*Word{list2.List[0], list2.List[3]}, filtered2.List)
+	assert.Equal(t, "1", filtered1.Group)
+	assert.Equal(t, "2", filtered2.Group)
+}
+
+func TestConceptSubset(t *testing.T) {
+	assert.Len(t, swadesh110, 110)
+	for name, size := range map[string]int{"swadesh100": 100, "yakhontov35": 35, "leipzig-jakarta": 100} {
+		var seen = map[string]bool{}
+		for _, gloss := range builtinConceptLists[name] {
+			assert.False(t, seen[gloss], gloss)
+			seen[gloss] = true
+		}
+		assert.Len(t, seen, size, name)
+	}
+
+	var list = &Wordlist{Group: "1", List: []*Word{
+		{SwadeshID: 9, SwadeshWord: "blood"},
+		{SwadeshID: 13, SwadeshWord: "claw(nail)"},
+		{SwadeshID: 48, SwadeshWord: "livera"},
+		{SwadeshID: 948, SwadeshWord: "WATER"},
+	}}
+	subset, err := NewConceptSubset("yakhontov35", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Word{list.List[0], list.List[3]}, subset.Filter(list).List)
+
+	subset, err = NewConceptSubset("leipzig-jakarta", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Word{list.List[0], list.List[3]}, subset.Filter(list).List)
+
+	subset, err = NewConceptSubset("swadesh100", true)
+	assert.NoError(t, err)
+	assert.Equal(t, list.List, subset.Filter(list).List)
+
+	_, err = NewConceptSubset("swadesh207", true)
+	assert.Error(t, err)
+}
+
+func TestLexicostatistics(t *testing.T) {
+	var (
+		list1 = &Wordlist{List: []*Word{
+			{SwadeshID: 1, Cognates: []int{1}},
+			{SwadeshID: 2, Cognates: []int{1, 2}},
+			{SwadeshID: 3, Cognates: []int{1}},
+			{SwadeshID: 4, Cognates: []int{1}},
+		}}
+		list2 = &Wordlist{List: []*Word{
+			{SwadeshID: 1, Cognates: []int{1}},
+			{SwadeshID: 2, Cognates: []int{2}},
+			{SwadeshID: 3, Cognates: []int{3}},
+			{SwadeshID: 4, Cognates: []int{0}},
+		}}
+	)
+	percentage, shared, total := CognatePercentage(list1, list2)
+	assert.Equal(t, 2, shared)
+	assert.Equal(t, 3, total)
+	assert.InDelta(t, 2./3, percentage, 1e-9)
+
+	assert.InDelta(t, 1., SwadeshTime(DefaultRetention*DefaultRetention, DefaultRetention), 1e-9)
+	// ln 0.5 / (-0.05 * 0.5) = 27.73, the square root of which is 5.27.
+	assert.InDelta(t, 5.266, StarostinTime(0.5, DefaultStarostinRate), 1e-3)
+	assert.Equal(t, 0., SwadeshTime(1, DefaultRetention))
+	assert.Equal(t, 0., StarostinTime(1, DefaultStarostinRate))
+	assert.True(t, math.IsNaN(SwadeshTime(0, DefaultRetention)))
+	assert.True(t, math.IsNaN(StarostinTime(0, DefaultStarostinRate)))
+
+	tree, err := UPGMA(
+		[]string{"Proto A", "B", "C"},
+		[][]float64{
+			{0, 3, 3},
+			{3, 0, 1},
+			{3, 1, 0},
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "('Proto A':3.000,(B:1.000,C:1.000):2.000);", tree)
+	tree, err = UPGMA([]string{"A"}, [][]float64{{0}})
+	assert.NoError(t, err)
+	assert.Equal(t, "A;", tree)
+
+	_, err = UPGMA(
+		[]string{"A", "B", "C"},
+		[][]float64{
+			{0, math.NaN(), 3},
+			{math.NaN(), 0, 1},
+			{3, 1, 0},
+		})
+	assert.Error(t, err)
+}
+
+func TestWriteNexus(t *testing.T) {
+	var (
+		out       strings.Builder
+		wordlists = []*Wordlist{
+			{Group: "Latin", List: []*Word{
+				{SwadeshID: 1, SwadeshWord: "claw(nail)", Cognates: []int{1}},
+				{SwadeshID: 2, SwadeshWord: "I ", Cognates: []int{1, 2}},
+			}},
+			{Group: "Old Greek", List: []*Word{
+				{SwadeshID: 1, SwadeshWord: "claw(nail)", Cognates: []int{2}},
+				{SwadeshID: 2, SwadeshWord: "I ", Cognates: []int{0}},
+			}},
+			{Group: "Gothic", List: []*Word{
+				{SwadeshID: 2, SwadeshWord: "I ", Cognates: []int{3}},
+			}},
+		}
+	)
+	assert.NoError(t, writeNexus(&out, wordlists))
+	assert.Equal(t, `#NEXUS
+
+BEGIN DATA;
+	DIMENSIONS NTAX=3 NCHAR=5;
+	FORMAT DATATYPE=STANDARD MISSING=? GAP=- SYMBOLS="01";
+	CHARSTATELABELS
+		1 claw_1,
+		2 claw_2,
+		3 I_1,
+		4 I_2,
+		5 I_3
+	;
+	MATRIX
+	Latin        10110
+	'Old Greek'  01???
+	Gothic       ??001
+	;
+END;
+`, out.String())
+
+	assert.Error(t, writeNexus(&out, []*Wordlist{{Group: "Latin", List: []*Word{{SwadeshID: 1}}}}))
+}
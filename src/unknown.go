@@ -0,0 +1,122 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+const maxUnknownExamples = 3
+
+// UnknownSound is a character the sound model has no class for; such characters
+// are dropped from decoded forms.
+type UnknownSound struct {
+	Char     rune
+	Count    int
+	Examples []*UnknownExample
+}
+
+// UnknownExample tells where an unknown character was seen.
+type UnknownExample struct {
+	Form        string
+	Group       string
+	SwadeshID   int
+	SwadeshWord string
+}
+
+func (s *UnknownSound) String() string {
+	var examples []string
+	for _, example := range s.Examples {
+		examples = append(examples, fmt.Sprintf("%s (%s, %d %s)",
+			example.Form, example.Group, example.SwadeshID, strings.TrimSpace(example.SwadeshWord)))
+	}
+
+	return fmt.Sprintf("%q (U+%04X): %d occurrence(s), e.g. %s",
+		s.Char, s.Char, s.Count, strings.Join(examples, "; "))
+}
+
+// UnknownSounds returns the characters dropped by all Decode calls so far,
+// most frequent first.
+func (d *SoundClassesDecoder) UnknownSounds() []*UnknownSound {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var out []*UnknownSound
+	for _, unknown := range d.unknownSounds {
+		unknownCopy := *unknown
+		unknownCopy.Examples = append([]*UnknownExample(nil), unknown.Examples...)
+		out = append(out, &unknownCopy)
+	}
+	sortUnknownSounds(out)
+
+	return out
+}
+
+// sortUnknownSounds puts the most frequent characters first.
+func sortUnknownSounds(unknowns []*UnknownSound) {
+	sort.Slice(unknowns, func(i, j int) bool {
+		if unknowns[i].Count != unknowns[j].Count {
+			return unknowns[i].Count > unknowns[j].Count
+		}
+		return unknowns[i].Char < unknowns[j].Char
+	})
+}
+
+func (d *SoundClassesDecoder) PrintUnknownSounds() {
+	var unknowns = d.UnknownSounds()
+	if len(unknowns) == 0 {
+		return
+	}
+
+	log.Printf("WARNING: %d character(s) are not in the sound model and were ignored:", len(unknowns))
+	for _, unknown := range unknowns {
+		log.Println(unknown)
+	}
+}
+
+// recordUnknownSounds counts the characters of clean forms that are not in the
+// sound model for UnknownSounds and returns how many were found.
+func (d *SoundClassesDecoder) recordUnknownSounds(clean []string, source *UnknownExample) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.countUnknownSounds(d.unknownSounds, clean, source)
+}
+
+// countUnknownSounds counts the characters of clean forms that are not in the
+// sound model into unknowns and returns how many were found.
+func (d *SoundClassesDecoder) countUnknownSounds(unknowns map[rune]*UnknownSound, clean []string,
+	source *UnknownExample) (found int) {
+	for _, form := range clean {
+		for _, segment := range d.segment(form) {
+			if len(segment.classID) > 0 {
+				continue
+			}
+
+			var char = []rune(segment.text)[0]
+			unknown, ok := unknowns[char]
+			if !ok {
+				unknown = &UnknownSound{Char: char}
+				unknowns[char] = unknown
+			}
+			unknown.Count++
+			if len(unknown.Examples) < maxUnknownExamples && !unknown.hasExample(source) {
+				unknown.Examples = append(unknown.Examples, source)
+			}
+			found++
+		}
+	}
+
+	return found
+}
+
+func (s *UnknownSound) hasExample(example *UnknownExample) bool {
+	for _, seen := range s.Examples {
+		if seen.Form == example.Form && seen.Group == example.Group {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,238 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "ERROR"
+	SeverityWarning Severity = "WARNING"
+)
+
+// ValidationIssue is a problem found in a wordlists file; Row is 1-based as in
+// spreadsheet editors, 0 for issues of the whole file.
+type ValidationIssue struct {
+	Severity Severity
+	Row      int
+	Column   string
+	Message  string
+}
+
+func (i *ValidationIssue) String() string {
+	var location string
+	if i.Row > 0 {
+		location = fmt.Sprintf("row %d", i.Row)
+	}
+	if len(i.Column) > 0 {
+		if len(location) > 0 {
+			location += ", "
+		}
+		location += i.Column
+	}
+	if len(location) > 0 {
+		return fmt.Sprintf("%s: %s: %s", i.Severity, location, i.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+}
+
+type ValidationReport struct {
+	Path   string
+	Issues []*ValidationIssue
+}
+
+func (r *ValidationReport) add(severity Severity, row int, column, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, &ValidationIssue{
+		Severity: severity,
+		Row:      row,
+		Column:   column,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *ValidationReport) NumErrors() (out int) {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			out++
+		}
+	}
+
+	return out
+}
+
+func (r *ValidationReport) Print() {
+	for _, issue := range r.Issues {
+		log.Println(issue)
+	}
+	log.Printf("%s: %d error(s), %d warning(s)", r.Path, r.NumErrors(), len(r.Issues)-r.NumErrors())
+}
+
+// Validate runs the input checks of Decode on a wordlists file without decoding
+// it. Languages with less than minCoverage of attested concepts are reported.
+// Unknown characters are errors in strict mode and warnings otherwise; they are
+// collected for the report only and not added to the unknown sounds of Decode.
+func (d *SoundClassesDecoder) Validate(listsPath string, selector *LanguageSelector, minCoverage float64) (
+	*ValidationReport, error) {
+	var profiles = d.normalizedProfiles()
+	rows, layout, err := openWordlists(listsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		report      = &ValidationReport{Path: listsPath}
+		headerLen   = len(rows[0].Cells)
+		languages   []*languageColumn
+		seenIDs     = map[int]int{}
+		attested    = map[string]map[int]bool{}
+		numConcepts int
+		lastID      int
+		hasLastID   bool
+	)
+	for _, language := range layout.languages {
+		if selector.Selected(language.name) {
+			languages = append(languages, language)
+			attested[language.name] = map[int]bool{}
+		}
+	}
+
+	// Unknown characters are collected apart from those of Decode calls.
+	var unknownSounds = map[rune]*UnknownSound{}
+	for idx := 1; idx < len(rows); idx++ {
+		var (
+			row    = rows[idx].Cells
+			rowNum = idx + 1
+		)
+		if len(row) < headerLen {
+			report.add(SeverityWarning, rowNum, "", "row has %d cell(s), header has %d", len(row), headerLen)
+		}
+
+		id, err := cellInt(row, layout.idCol)
+		if err != nil {
+			report.add(SeverityError, rowNum, "", "concept ID %q is not a number", cellString(row, layout.idCol))
+			continue
+		}
+
+		if !hasLastID || id != lastID {
+			if firstRow, ok := seenIDs[id]; ok {
+				report.add(SeverityError, rowNum, "", "concept ID %d is repeated (first seen in row %d)", id, firstRow)
+			} else if hasLastID && id < lastID {
+				report.add(SeverityError, rowNum, "", "concept ID %d follows %d (IDs must increase)", id, lastID)
+			}
+			if _, ok := seenIDs[id]; !ok {
+				seenIDs[id] = rowNum
+				numConcepts++
+			}
+			if len(strings.TrimSpace(cellString(row, layout.conceptCol))) == 0 {
+				report.add(SeverityWarning, rowNum, "", "concept %d has no gloss", id)
+			}
+		}
+		lastID, hasLastID = id, true
+
+		for _, language := range languages {
+			var form = strings.TrimSpace(cellString(row, language.col))
+			if len(form) == 0 {
+				continue
+			}
+
+			if problem := markupProblem(form, d.rules); len(problem) > 0 {
+				report.add(SeverityError, rowNum, language.name, "%s in %q", problem, form)
+				continue
+			}
+
+			var source = d.normalize(form)
+			if profile, ok := profiles[language.name]; ok {
+				source = profile.Apply(source)
+			}
+			var (
+				parsed         = d.parseForm(source, d.Affixes[language.name])
+				clean, decoded = parsed.clean, parsed.decoded
+			)
+			d.countUnknownSounds(unknownSounds, clean, &UnknownExample{Form: form, Group: language.name, SwadeshID: id})
+
+			var isAttested, hasEmpty bool
+			for _, root := range decoded {
+				isAttested, hasEmpty = isAttested || len(root) > 0, hasEmpty || len(root) == 0
+			}
+			if hasEmpty {
+				report.add(SeverityWarning, rowNum, language.name, "%q decodes to an empty root", form)
+			}
+			if isAttested {
+				attested[language.name][id] = true
+			}
+		}
+	}
+
+	var unknownSeverity = SeverityWarning
+	if d.Strict {
+		unknownSeverity = SeverityError
+	}
+	var unknowns []*UnknownSound
+	for _, unknown := range unknownSounds {
+		unknowns = append(unknowns, unknown)
+	}
+	sortUnknownSounds(unknowns)
+	for _, unknown := range unknowns {
+		var examples []string
+		for _, example := range unknown.Examples {
+			examples = append(examples, fmt.Sprintf("%s (%s, %d)", example.Form, example.Group, example.SwadeshID))
+		}
+		report.add(unknownSeverity, 0, "", "%q (%U) is not in the sound model: %d occurrence(s), e.g. %s",
+			unknown.Char, unknown.Char, unknown.Count, strings.Join(examples, "; "))
+	}
+
+	var names []string
+	for name := range attested {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if numConcepts == 0 {
+			break
+		}
+		if coverage := float64(len(attested[name])) / float64(numConcepts); coverage < minCoverage {
+			report.add(SeverityWarning, 0, name, "coverage is %.1f%% (%d / %d concept(s))",
+				100*coverage, len(attested[name]), numConcepts)
+		}
+	}
+
+	return report, nil
+}
+
+// markupProblem describes malformed markup of a form, empty if there is none.
+func markupProblem(form string, rules *RootRules) string {
+	var depth int
+	for _, char := range form {
+		switch char {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			if depth--; depth < 0 {
+				return "unbalanced brackets"
+			}
+		}
+	}
+	if depth != 0 {
+		return "unbalanced brackets"
+	}
+
+	var stripped = stripComments(form)
+	for _, marker := range rules.VariantMarkers {
+		if !strings.ContainsRune(stripped, marker) {
+			continue
+		}
+		for _, variant := range strings.Split(stripped, string(marker)) {
+			if len(strings.Trim(variant, " "+rules.StripMarkers+rules.DoubtMarkers+rules.LoanMarkers)) == 0 {
+				return fmt.Sprintf("empty variant around %q", marker)
+			}
+		}
+		break
+	}
+
+	return ""
+}